@@ -1,40 +1,54 @@
 package collector
 
 import (
-	"bufio"
-	"compress/gzip"
-	"fmt"
+	"context"
+	"get_package_md5/collector/byhttp/config"
+	"get_package_md5/collector/byhttp/discovery"
+	"get_package_md5/collector/byhttp/limiter"
+	"get_package_md5/collector/byhttp/mirror"
 	"get_package_md5/collector/byhttp/recorder"
 	"get_package_md5/collector/byhttp/sleeper"
+	"get_package_md5/model"
 	"get_package_md5/parser"
+	"get_package_md5/utils"
 	"io"
 	"log"
 	"net/http"
-	"net/url"
 	"os"
 	"path"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/sourcegraph/conc/pool"
 )
 
+// maxAttempts 单个请求的最大尝试次数
+const maxAttempts = 5
+
+// ErrNotFound 远端返回404
+var ErrNotFound = errors.New("status code 404")
+
 type Collector struct {
 	HTTPClient http.Client
 	Recorder   *recorder.AccessRecorder
 	pool       *pool.Pool
 	sleeper    *sleeper.Sleeper
+	limiter    *limiter.Limiter
 	outDir     string
+	stats      Stats
 }
 
 func NewCollector(httpCli http.Client, recorder *recorder.AccessRecorder,
-	pool *pool.Pool, sleeper *sleeper.Sleeper, out string) *Collector {
+	pool *pool.Pool, sleeper *sleeper.Sleeper, limiter *limiter.Limiter, out string) *Collector {
 
 	return &Collector{
 		pool:       pool,
 		HTTPClient: httpCli,
 		sleeper:    sleeper,
+		limiter:    limiter,
 		Recorder:   recorder,
 		outDir:     out,
 	}
@@ -44,151 +58,297 @@ func (c *Collector) Wait() {
 	c.pool.Wait()
 }
 
-// var host = "https://mirrors.ustc.edu.cn/ubuntu/pool/universe/"
-// var list = []string{"o", "p", "q", "r", "s", "t", "u", "v", "w", "x", "y", "z"}
-// var host = "https://mirrors.ustc.edu.cn/alpine/"
-// var list = []string{"v3.9", "v3.10", "v3.11", "v3.12", "v3.13", "v3.14", "v3.15", "v3.16", "v3.17", "v3.18", "v3.19"}
-
-func (c *Collector) Start(do func(string)) {
-	for pkgType, host := range hostRegisters {
-		log.Printf("start to collecting %s packages from %s\n", pkgType, host)
-		err := c.Visit(host, do)
-		if err != nil {
+// Start 从所有注册的源发现包，ctx取消后停止发现，已提交的下载不受影响
+func (c *Collector) Start(ctx context.Context, do func(*discovery.Package)) {
+	for pkgType, src := range sourceRegisters {
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("start to discovering %s packages from repository index\n", pkgType)
+		if err := c.discover(ctx, pkgType, src, do); err != nil {
 			log.Println(err)
 		}
 	}
 }
 
-func (c *Collector) Visit(url string, do func(nextUrl string)) error {
-	request, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return errors.WithMessagef(err, "create request for %s", url)
+// discover 从发现源获取包并提交到goroutine池，应用配置文件中的过滤、并发与限速规则
+func (c *Collector) discover(ctx context.Context, pkgType string, src discovery.Source, do func(*discovery.Package)) error {
+	group := mirrorRegisters[pkgType]
+	opt := optionRegisters[pkgType]
+
+	var sem chan struct{}
+	if opt != nil {
+		// 未配置rate时沿用全局限速
+		if opt.Rate > 0 {
+			for _, host := range group.Hosts() {
+				c.limiter.SetHostRate(host, opt.Rate, opt.Burst)
+			}
+		}
+		if opt.Concurrency > 0 {
+			sem = make(chan struct{}, opt.Concurrency)
+		}
 	}
 
-	resp, err := c.HTTPClient.Do(request)
-	if err != nil {
-		return errors.WithMessagef(err, "visit %s", url)
-	}
+	return src.Discover(ctx, func(p string) (io.ReadCloser, error) {
+		return c.fetchMirror(ctx, group, p)
+	}, func(pkg *discovery.Package) {
+		if ctx.Err() != nil {
+			return
+		}
+		// xml:base为完整地址的包没有相对路径，按url中的路径匹配
+		p := pkg.Path
+		if p == "" {
+			p = pkg.Key()
+		}
+		if opt != nil && !opt.Match(p) {
+			return
+		}
+		pkg.Repo = pkgType
+		atomic.AddInt64(&c.stats.Discovered, 1)
+		if sem != nil {
+			sem <- struct{}{}
+		}
+		c.pool.Go(func() {
+			if sem != nil {
+				defer func() { <-sem }()
+			}
+			do(pkg)
+		})
+	})
+}
 
-	if resp != nil {
-		defer resp.Body.Close()
+// fetch 获取url内容，非200状态码视为错误，调用方负责关闭返回的body
+// 请求经过按host的限速器，遇到网络错误或封禁状态码时按Retry-After或指数退避重试
+func (c *Collector) fetch(ctx context.Context, url string) (io.ReadCloser, error) {
+	request, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "create request for %s", url)
 	}
+	host := request.URL.Host
 
-	if resp.StatusCode != 200 {
-		if isBanCode(resp.StatusCode) {
-			c.Recorder.RecordError("Access denied")
-			os.Exit(0)
+	var lastErr error
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if err := c.limiter.Wait(ctx, host); err != nil {
+			return nil, err
 		}
-		return errors.Errorf("status code %d", resp.StatusCode)
-	}
 
-	if err := c.walkResp(url, resp, do); err != nil {
-		return errors.WithMessagef(err, "process %s", url)
-	}
+		resp, err := c.HTTPClient.Do(request)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			lastErr = withClass(recorder.ClassNetwork, err)
+			c.backoff(ctx, host, attempt)
+			continue
+		}
 
-	return nil
-}
+		if resp.StatusCode == 200 {
+			c.limiter.Success(host)
+			return resp.Body, nil
+		}
+		resp.Body.Close()
 
-func (c *Collector) walkResp(root string, resp *http.Response, do func(string)) error {
-	return c.iterateHrefs(resp.Body, func(href string) error {
-		nextUrl := strings.TrimSuffix(root, "/") + "/" + href
-		if isDirHref(href) {
-			if err := c.Visit(nextUrl, do); err != nil {
-				c.Recorder.RecordError(err.Error())
-			}
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, withClass(recorder.ClassStatus, ErrNotFound)
+		}
+		lastErr = withClass(recorder.ClassStatus, errors.Errorf("status code %d", resp.StatusCode))
+		if !isBanCode(resp.StatusCode) {
+			return nil, lastErr
 		}
-		for _, p := range parserRegisters {
-			if !p.Check(href) {
-				continue
+		if d, ok := limiter.RetryAfter(resp.Header); ok {
+			// 过长的Retry-After会使该host长时间停滞，不超过退避的上限
+			if d > sleeper.MaxBackoff {
+				log.Printf("%s asks to retry after %s, clamped to %s\n", host, d, sleeper.MaxBackoff)
+				d = sleeper.MaxBackoff
+			} else {
+				log.Printf("%s asks to retry after %s\n", host, d)
 			}
-			c.pool.Go(func() {
-				do(nextUrl)
-			})
-			break
+			c.limiter.Pause(host, d)
+			continue
 		}
-		return nil
-	})
+		c.backoff(ctx, host, attempt)
+	}
+
+	return nil, errors.WithMessagef(lastErr, "give up after %d attempts", maxAttempts)
 }
 
-func (c *Collector) iterateHrefs(r io.Reader, do func(string) error) error {
-	var (
-		lines   []string
-		scanner = bufio.NewScanner(r)
-	)
+// backoff 记录一次失败并退避，连续失败过多时由limiter暂停整个host
+func (c *Collector) backoff(ctx context.Context, host string, attempt int) {
+	if c.limiter.Failure(host) {
+		log.Printf("too many failures from %s, pause it for a while\n", host)
+		return
+	}
+	c.sleeper.Backoff(ctx, attempt)
+}
 
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		lines = append(lines, line)
+// DownloadAndParse 下载并解析包，ctx取消时中断下载，结果不会被记录为已完成
+func (c *Collector) DownloadAndParse(ctx context.Context, pkg *discovery.Package) error {
+	key := pkg.Key()
+	if c.Recorder.Exist(key) {
+		atomic.AddInt64(&c.stats.Skipped, 1)
+		log.Printf("skip downloaded package %s\n", key)
+		return nil
 	}
 
-	for _, line := range lines {
-		if strings.HasPrefix(line, `<a href="`) {
-			left := strings.Index(line, `="`) + 2
-			right := strings.Index(line, `">`)
-			if left > right {
-				log.Println("parse error", line)
-				continue
-			}
-			if err := do(line[left:right]); err != nil {
-				return err
-			}
-		}
+	p := findParser(key)
+	if p == nil {
+		return errors.Errorf("no parser for %s", key)
 	}
 
-	return nil
-}
+	meta := pkg.Meta
+	if meta == nil {
+		meta = new(model.IndexMeta)
+	}
+	meta.Path = key
 
-func (c *Collector) DownloadAndParse(u string) error {
-	uu, err := url.Parse(u)
+	var (
+		doc interface{}
+		err error
+	)
+	switch {
+	case pkg.File != "":
+		doc, err = c.parseFile(ctx, pkg.File, p, meta)
+	case pkg.Path == "":
+		doc, err = c.download(ctx, nil, nil, pkg.URL, p, meta)
+	default:
+		group, ok := mirrorRegisters[pkg.Repo]
+		if !ok {
+			return errors.Errorf("no mirrors registered for %s", pkg.Repo)
+		}
+		// 依次尝试各镜像，解析失败或格式不支持与镜像无关，不再切换
+		for _, m := range group.Candidates() {
+			doc, err = c.download(ctx, group, m, m.URL(pkg.Path), p, meta)
+			if err == nil || ctx.Err() != nil || !mirrorError(err) {
+				break
+			}
+			log.Println(err)
+		}
+	}
 	if err != nil {
-		return errors.WithMessagef(err, "parse %s", u)
+		// 因取消而失败时不记录失败，下次运行重新下载
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
 	}
 
-	if c.Recorder.Exist(uu.Path) {
-		log.Printf("skip downloaded url %s\n", uu.String())
-		return nil
+	dir := filepath.Join(c.outDir, key)
+	if _, err := os.Stat(dir); err != nil {
+		if err := os.MkdirAll(dir, 0644); err != nil {
+			return errors.WithMessagef(err, "create dir %s", dir)
+		}
+	}
+	saveName := strings.TrimSuffix(filepath.Base(key), filepath.Ext(key)) + ".json"
+	if err := utils.SaveJson(doc, path.Join(dir, saveName)); err != nil {
+		return errors.WithMessagef(err, "save json of %s", key)
 	}
-	log.Printf("downloading %s\n", uu.String())
 
-	// c.sleeper.Sleep()
+	c.Recorder.Record(key)
+	atomic.AddInt64(&c.stats.Succeeded, 1)
+	log.Printf("download %s success\n", key)
 
-	req, err := http.NewRequest("GET", uu.String(), nil)
-	if err != nil {
-		return errors.WithMessagef(err, "download %s", uu.String())
+	return nil
+}
+
+// download 从u下载并解析、校验，group不为nil时将结果反馈给镜像组
+func (c *Collector) download(ctx context.Context, group *mirror.Group, m *mirror.Mirror, u string, p parser.Parser, meta *model.IndexMeta) (interface{}, error) {
+	log.Printf("downloading %s\n", u)
+
+	start := time.Now()
+	body, err := c.fetch(ctx, u)
+	if group != nil {
+		group.Report(m, time.Since(start), healthError(err))
 	}
-	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
-		return errors.WithMessagef(err, "download %s", uu.String())
+		return nil, errors.WithMessagef(err, "download %s", u)
 	}
-	if resp != nil {
-		defer resp.Body.Close()
+	defer body.Close()
+
+	doc, err := c.parse(ctx, body, u, p, meta)
+	// 校验失败可能与镜像有关，反馈给镜像组
+	if err != nil && group != nil && mirrorError(err) {
+		group.Report(m, 0, err)
 	}
+	return doc, err
+}
 
-	dir := filepath.Join(c.outDir, uu.Path)
-	if _, err := os.Stat(dir); err != nil {
-		if err := os.MkdirAll(dir, 0644); err != nil {
-			return errors.WithMessagef(err, "create dir %s", dir)
+// parse 解析并校验r的内容，u仅用于错误信息
+func (c *Collector) parse(ctx context.Context, r io.Reader, u string, p parser.Parser, meta *model.IndexMeta) (interface{}, error) {
+	vr := newVerifyReader(r, meta)
+	doc, err := p.Parse(ctx, vr, meta)
+	// 解析失败时同样读完并校验，内容完整通过校验后才能认定是包本身的问题
+	verr := vr.Verify()
+	if err != nil {
+		// 传输中断导致的解析失败与包无关，按网络错误处理，可以换一个镜像重试
+		if vr.err != nil {
+			return nil, withClass(recorder.ClassNetwork, errors.WithMessagef(vr.err, "read %s", u))
+		}
+		// 镜像返回的错误页面或损坏的文件，可以换一个镜像重试
+		if errors.Is(verr, ErrChecksumMismatch) {
+			return nil, withClass(recorder.ClassChecksum, errors.WithMessagef(err, "parse %s (%s)", u, verr))
+		}
+		class := recorder.ClassParse
+		if errors.Is(err, parser.ErrUnsupported) {
+			class = recorder.ClassUnsupported
 		}
+		return nil, withClass(class, errors.WithMessagef(err, "parse %s", u))
+	}
+	// 校验失败时不保存结果也不记录为已下载，作为失败记录等待重试
+	if verr != nil {
+		class := recorder.ClassNetwork
+		if errors.Is(verr, ErrChecksumMismatch) {
+			class = recorder.ClassChecksum
+		}
+		return nil, withClass(class, errors.WithMessagef(verr, "verify %s", u))
 	}
-	saveName := strings.TrimSuffix(filepath.Base(uu.Path), filepath.Ext(uu.Path)) + ".json"
 
-	for _, p := range parserRegisters {
-		if p.Check(uu.Path) {
-			if err := p.Parse(resp.Body, path.Join(dir, saveName)); err != nil {
-				return errors.WithMessagef(err, "parse %s", uu.String())
-			}
-			break
+	return doc, nil
+}
+
+// fetchMirror 按镜像组的顺序获取相对路径p，失败时切换到下一个镜像
+func (c *Collector) fetchMirror(ctx context.Context, group *mirror.Group, p string) (io.ReadCloser, error) {
+	if group == nil {
+		return nil, errors.Errorf("no mirrors for %s", p)
+	}
+	var lastErr error
+	for _, m := range group.Candidates() {
+		start := time.Now()
+		body, err := c.fetch(ctx, m.URL(p))
+		if err == nil {
+			group.Report(m, time.Since(start), nil)
+			return body, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
 		}
+		group.Report(m, time.Since(start), healthError(err))
+		lastErr = errors.WithMessagef(err, "fetch %s", m.URL(p))
 	}
+	return nil, lastErr
+}
 
-	c.Recorder.Record(uu.Path)
-	log.Printf("download %s success\n", uu.String())
+// healthError 过滤掉不反映镜像健康状况的错误，如镜像未同步导致的404
+func healthError(err error) error {
+	if errors.Is(err, ErrNotFound) || errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
 
-	return nil
+// mirrorError 换一个镜像是否可能成功
+func mirrorError(err error) bool {
+	class := errorClass(err)
+	return class != recorder.ClassParse && class != recorder.ClassUnsupported
 }
 
-func isDirHref(p string) bool {
-	return strings.HasSuffix(p, "/")
+// findParser 返回支持name的解析器，没有时返回nil
+func findParser(name string) parser.Parser {
+	for _, p := range parserRegisters {
+		if p.Check(name) {
+			return p
+		}
+	}
+	return nil
 }
 
 func isBanCode(code int) bool {
@@ -207,99 +367,289 @@ func Register(pkgTypes []string) {
 			RegisterAlpine()
 		case "centos":
 			RegisterRpm()
+		case "rocky":
+			RegisterRocky()
+		case "almalinux":
+			RegisterAlma()
+		case "fedora":
+			RegisterFedora()
+		case "openeuler":
+			RegisterOpenEuler()
+		case "archlinux":
+			RegisterArch()
+		case "manjaro":
+			RegisterManjaro()
+		case "openwrt":
+			RegisterOpenWrt()
 		default:
 			log.Printf("invalid pkg type %s\n", pkgType)
 		}
 	}
 }
 
-var hostRegisters = map[string]string{}
+// RegisterParsers 注册所有支持的包格式的解析器，不注册发现源，用于解析本地文件
+func RegisterParsers() {
+	parserRegisters["deb"] = parser.NewDebParser()
+	parserRegisters["apk"] = parser.NewApkParser()
+	parserRegisters["rpm"] = parser.NewRpmParser()
+	parserRegisters["pacman"] = parser.NewPacmanParser()
+	parserRegisters["ipk"] = parser.NewIpkParser()
+	parserRegisters["snap"] = parser.NewSnapParser()
+	parserRegisters["appimage"] = parser.NewAppImageParser()
+}
+
+var sourceRegisters = map[string]discovery.Source{}
+var mirrorRegisters = map[string]*mirror.Group{}
 var parserRegisters = map[string]parser.Parser{}
 
-func RegisterUbuntu() {
-	hostRegisters["ubuntu"] = "https://mirrors.ustc.edu.cn/ubuntu/pool"
+// optionRegisters 通过配置文件注册的源的过滤、并发与限速规则
+var optionRegisters = map[string]*config.Source{}
+
+// RegisterConfig 按配置文件注册一个源，配置需已通过校验
+func RegisterConfig(s *config.Source) {
+	mirrorRegisters[s.Name] = mirror.NewGroup(s.Mirrors...)
+	optionRegisters[s.Name] = s
+
+	switch s.Type {
+	case "deb":
+		sourceRegisters[s.Name] = &discovery.DebIndex{
+			Suites:     s.Suites,
+			Components: s.Components,
+			Arches:     s.Architectures,
+		}
+		parserRegisters[s.Name] = parser.NewDebParser()
+	case "apk":
+		sourceRegisters[s.Name] = &discovery.ApkIndex{
+			Branches: s.Suites,
+			Repos:    s.Components,
+			Arches:   s.Architectures,
+		}
+		parserRegisters[s.Name] = parser.NewApkParser()
+	case "rpm":
+		var repos []discovery.RpmRepo
+		for _, r := range s.Repos {
+			repo := discovery.RpmRepo{ID: r.ID, Dir: r.Dir, OS: r.OS}
+			if repo.ID == "" {
+				repo.ID = s.Name + "-" + strings.Trim(r.Dir, "/")
+			}
+			if repo.OS == "" {
+				repo.OS = s.OS
+			}
+			repos = append(repos, repo)
+		}
+		sourceRegisters[s.Name] = &discovery.RpmIndex{Repos: repos}
+		parserRegisters[s.Name] = parser.NewRpmParser()
+	case "ipk":
+		sourceRegisters[s.Name] = &discovery.IpkIndex{
+			Releases: s.Suites,
+			Feeds:    s.Components,
+			Arches:   s.Architectures,
+			Targets:  s.Targets,
+		}
+		parserRegisters[s.Name] = parser.NewIpkParser()
+	case "pacman":
+		var repos []discovery.PacmanRepo
+		for _, r := range s.Repos {
+			repo := discovery.PacmanRepo{Name: r.ID, Dir: r.Dir, OS: r.OS}
+			if repo.OS == "" {
+				repo.OS = s.OS
+			}
+			repos = append(repos, repo)
+		}
+		sourceRegisters[s.Name] = &discovery.PacmanIndex{Repos: repos}
+		parserRegisters[s.Name] = parser.NewPacmanParser()
+	}
+}
+
+// registerMirrors 未指定镜像时使用默认镜像，镜像按顺序作为初始优先级
+func registerMirrors(name string, mirrors []string, defaults ...string) {
+	if len(mirrors) == 0 {
+		mirrors = defaults
+	}
+	mirrorRegisters[name] = mirror.NewGroup(mirrors...)
+}
+
+func RegisterUbuntu(mirrors ...string) {
+	registerMirrors("ubuntu", mirrors,
+		"https://mirrors.ustc.edu.cn/ubuntu/",
+		"https://mirrors.tuna.tsinghua.edu.cn/ubuntu/",
+		"https://mirrors.aliyun.com/ubuntu/")
+	sourceRegisters["ubuntu"] = &discovery.DebIndex{
+		Suites: []string{"bionic", "bionic-updates", "bionic-security",
+			"focal", "focal-updates", "focal-security",
+			"jammy", "jammy-updates", "jammy-security",
+			"noble", "noble-updates", "noble-security"},
+	}
 	parserRegisters["ubuntu"] = parser.NewDebParser()
 }
 
-func RegisterAlpine() {
-	hostRegisters["alpine"] = "https://mirrors.ustc.edu.cn/alpine/"
+func RegisterAlpine(mirrors ...string) {
+	registerMirrors("alpine", mirrors,
+		"https://mirrors.ustc.edu.cn/alpine/",
+		"https://mirrors.tuna.tsinghua.edu.cn/alpine/",
+		"https://mirrors.aliyun.com/alpine/")
+	sourceRegisters["alpine"] = &discovery.ApkIndex{
+		Branches: []string{"v3.9", "v3.10", "v3.11", "v3.12", "v3.13", "v3.14",
+			"v3.15", "v3.16", "v3.17", "v3.18", "v3.19", "edge"},
+		Repos:  []string{"main", "community", "testing"},
+		Arches: []string{"x86_64", "x86", "aarch64", "armhf", "armv7", "ppc64le", "s390x"},
+	}
 	parserRegisters["alpine"] = parser.NewApkParser()
 }
 
-func RegisterRpm() {
-	hostRegisters["rpm"] = "https://mirrors.ustc.edu.cn/centos/"
+func RegisterRpm(mirrors ...string) {
+	registerMirrors("centos", mirrors,
+		"https://mirrors.ustc.edu.cn/centos/",
+		"https://mirrors.tuna.tsinghua.edu.cn/centos/",
+		"https://mirrors.aliyun.com/centos/")
+	var repos []discovery.RpmRepo
+	for _, r := range []string{"os", "updates", "extras"} {
+		repos = append(repos, discovery.RpmRepo{
+			ID:  "centos-7-" + r,
+			Dir: "7/" + r + "/x86_64/",
+			OS:  "centos 7",
+		})
+	}
+	sourceRegisters["centos"] = &discovery.RpmIndex{Repos: repos}
 	parserRegisters["rpm"] = parser.NewRpmParser()
 }
 
-func RegisterDebian() {
-	hostRegisters["debian"] = "https://mirrors.ustc.edu.cn/debian/pool"
-	parserRegisters["debian"] = parser.NewDebParser()
+func RegisterRocky(mirrors ...string) {
+	registerMirrors("rocky", mirrors,
+		"https://mirrors.ustc.edu.cn/rocky/",
+		"https://mirrors.tuna.tsinghua.edu.cn/rocky/",
+		"https://mirrors.aliyun.com/rockylinux/")
+	var repos []discovery.RpmRepo
+	for _, ver := range []string{"8", "9"} {
+		for _, r := range []string{"BaseOS", "AppStream"} {
+			repos = append(repos, discovery.RpmRepo{
+				ID:  "rocky-" + ver + "-" + r,
+				Dir: ver + "/" + r + "/x86_64/os/",
+				OS:  "rocky " + ver,
+			})
+		}
+	}
+	sourceRegisters["rocky"] = &discovery.RpmIndex{Repos: repos}
+	parserRegisters["rpm"] = parser.NewRpmParser()
 }
 
-func (c *Collector) visitDeb(pkgType string, do func(string)) error {
-	log.Printf("getting remote deb url list...\n")
-	urls, err := GetDebFileList(pkgType)
-	log.Printf("getting url list success,total %d\n", len(urls))
-	if err != nil {
-		return err
-	}
-	for _, u := range urls {
-		u := u
-		c.pool.Go(func() {
-			do(u)
-		})
+func RegisterAlma(mirrors ...string) {
+	registerMirrors("almalinux", mirrors,
+		"https://mirrors.ustc.edu.cn/almalinux/",
+		"https://mirrors.tuna.tsinghua.edu.cn/almalinux/",
+		"https://mirrors.aliyun.com/almalinux/")
+	var repos []discovery.RpmRepo
+	for _, ver := range []string{"8", "9"} {
+		for _, r := range []string{"BaseOS", "AppStream"} {
+			repos = append(repos, discovery.RpmRepo{
+				ID:  "almalinux-" + ver + "-" + r,
+				Dir: ver + "/" + r + "/x86_64/os/",
+				OS:  "almalinux " + ver,
+			})
+		}
 	}
-	return nil
+	sourceRegisters["almalinux"] = &discovery.RpmIndex{Repos: repos}
+	parserRegisters["rpm"] = parser.NewRpmParser()
 }
 
-func GetDebFileList(pkgType string) ([]string, error) {
-	u := ""
-	if pkgType == "ubuntu" {
-		u = "https://mirrors.ustc.edu.cn/ubuntu/ubuntu/ls-lR.gz"
-	} else if pkgType == "debian" {
-		u = "https://mirrors.ustc.edu.cn/debian/ls-lR.gz"
-	} else {
-		return nil, fmt.Errorf("invalid pkg type %s", pkgType)
+func RegisterFedora(mirrors ...string) {
+	registerMirrors("fedora", mirrors,
+		"https://mirrors.ustc.edu.cn/fedora/",
+		"https://mirrors.tuna.tsinghua.edu.cn/fedora/")
+	var repos []discovery.RpmRepo
+	for _, ver := range []string{"38", "39", "40"} {
+		repos = append(repos, discovery.RpmRepo{
+			ID:  "fedora-" + ver,
+			Dir: "releases/" + ver + "/Everything/x86_64/os/",
+			OS:  "fedora " + ver,
+		}, discovery.RpmRepo{
+			ID:  "fedora-" + ver + "-updates",
+			Dir: "updates/" + ver + "/Everything/x86_64/",
+			OS:  "fedora " + ver,
+		})
 	}
+	sourceRegisters["fedora"] = &discovery.RpmIndex{Repos: repos}
+	parserRegisters["rpm"] = parser.NewRpmParser()
+}
 
-	resp, err := http.Get(u)
-	if err != nil {
-		return nil, err
-	}
-	if resp != nil {
-		defer resp.Body.Close()
+func RegisterOpenEuler(mirrors ...string) {
+	registerMirrors("openeuler", mirrors,
+		"https://mirrors.ustc.edu.cn/openeuler/",
+		"https://repo.openeuler.org/")
+	var repos []discovery.RpmRepo
+	for _, ver := range []string{"20.03-LTS-SP4", "22.03-LTS-SP3"} {
+		for _, r := range []string{"OS", "everything", "update"} {
+			repos = append(repos, discovery.RpmRepo{
+				ID:  "openeuler-" + ver + "-" + r,
+				Dir: "openEuler-" + ver + "/" + r + "/x86_64/",
+				OS:  "openeuler " + ver,
+			})
+		}
 	}
+	sourceRegisters["openeuler"] = &discovery.RpmIndex{Repos: repos}
+	parserRegisters["rpm"] = parser.NewRpmParser()
+}
 
-	gr, err := gzip.NewReader(resp.Body)
-	if err != nil {
-		return nil, nil
+func RegisterDebian(mirrors ...string) {
+	registerMirrors("debian", mirrors,
+		"https://mirrors.ustc.edu.cn/debian/",
+		"https://mirrors.tuna.tsinghua.edu.cn/debian/",
+		"https://mirrors.aliyun.com/debian/")
+	sourceRegisters["debian"] = &discovery.DebIndex{
+		Suites: []string{"buster", "buster-updates",
+			"bullseye", "bullseye-updates",
+			"bookworm", "bookworm-updates",
+			"trixie"},
 	}
+	parserRegisters["debian"] = parser.NewDebParser()
+}
 
-	data, err := io.ReadAll(gr)
-	if err != nil {
-		return nil, err
+func RegisterArch(mirrors ...string) {
+	registerMirrors("archlinux", mirrors,
+		"https://mirrors.ustc.edu.cn/archlinux/",
+		"https://mirrors.tuna.tsinghua.edu.cn/archlinux/",
+		"https://mirrors.aliyun.com/archlinux/")
+	var repos []discovery.PacmanRepo
+	for _, r := range []string{"core", "extra", "multilib"} {
+		repos = append(repos, discovery.PacmanRepo{
+			Name: r,
+			Dir:  r + "/os/x86_64/",
+			OS:   "archlinux",
+		})
 	}
+	sourceRegisters["archlinux"] = &discovery.PacmanIndex{Repos: repos}
+	parserRegisters["pacman"] = parser.NewPacmanParser()
+}
 
-	var allFiles []string
-	parts := strings.Split(string(data), "\n\n")
-	for _, part := range parts {
-		part = strings.TrimSpace(part)
-		lines := strings.Split(part, "\n")
-		if len(lines) < 3 {
-			continue
-		}
-		d := strings.TrimPrefix(strings.TrimSuffix(lines[0], ":"), "./")
-		if !strings.HasPrefix(d, "pool") {
-			continue
-		}
-		for _, line := range lines {
-			if strings.HasSuffix(line, ".deb") ||
-				strings.HasSuffix(line, ".udeb") {
-				pls := strings.Split(line, " ")
-				allFiles = append(allFiles, hostRegisters[pkgType]+d+"/"+strings.TrimSpace(pls[len(pls)-1]))
-			}
+func RegisterManjaro(mirrors ...string) {
+	registerMirrors("manjaro", mirrors,
+		"https://mirrors.ustc.edu.cn/manjaro/",
+		"https://mirrors.tuna.tsinghua.edu.cn/manjaro/",
+		"https://mirrors.aliyun.com/manjaro/")
+	var repos []discovery.PacmanRepo
+	for _, branch := range []string{"stable"} {
+		for _, r := range []string{"core", "extra", "multilib"} {
+			repos = append(repos, discovery.PacmanRepo{
+				Name: r,
+				Dir:  branch + "/" + r + "/x86_64/",
+				OS:   "manjaro " + branch,
+			})
 		}
 	}
+	sourceRegisters["manjaro"] = &discovery.PacmanIndex{Repos: repos}
+	parserRegisters["pacman"] = parser.NewPacmanParser()
+}
 
-	return allFiles, nil
+func RegisterOpenWrt(mirrors ...string) {
+	registerMirrors("openwrt", mirrors,
+		"https://mirrors.ustc.edu.cn/openwrt/",
+		"https://mirrors.tuna.tsinghua.edu.cn/openwrt/",
+		"https://downloads.openwrt.org/")
+	sourceRegisters["openwrt"] = &discovery.IpkIndex{
+		Releases: []string{"22.03.7", "23.05.5"},
+		Feeds:    []string{"base", "packages", "luci", "routing", "telephony"},
+		Arches:   []string{"x86_64", "aarch64_cortex-a53", "arm_cortex-a7_neon-vfpv4", "mipsel_24kc", "mips_24kc"},
+		Targets:  []string{"x86/64", "mediatek/filogic", "ramips/mt7621", "ath79/generic"},
+	}
+	parserRegisters["ipk"] = parser.NewIpkParser()
 }
@@ -0,0 +1,112 @@
+package collector
+
+import (
+	"context"
+	"get_package_md5/collector/byhttp/discovery"
+	"get_package_md5/collector/byhttp/mirror"
+	"get_package_md5/collector/byhttp/recorder"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// classError 带失败分类的错误，分类见recorder.Class*
+type classError struct {
+	class string
+	err   error
+}
+
+func (e *classError) Error() string {
+	return e.err.Error()
+}
+
+func (e *classError) Unwrap() error {
+	return e.err
+}
+
+func withClass(class string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &classError{class: class, err: err}
+}
+
+func errorClass(err error) string {
+	var ce *classError
+	if errors.As(err, &ce) {
+		return ce.class
+	}
+	return recorder.ClassUnknown
+}
+
+// RecordFailure 将下载失败写入recorder，以便retry模式重新处理
+// 因退出而取消的下载只计数，下次运行时会重新下载
+func (c *Collector) RecordFailure(pkg *discovery.Package, err error) {
+	if errors.Is(err, context.Canceled) {
+		atomic.AddInt64(&c.stats.Cancelled, 1)
+		return
+	}
+	atomic.AddInt64(&c.stats.Failed, 1)
+	c.Recorder.RecordError(err.Error())
+	f := &recorder.Failure{
+		Key:   pkg.Key(),
+		URL:   pkg.URL,
+		Repo:  pkg.Repo,
+		Path:  pkg.Path,
+		File:  pkg.File,
+		Meta:  pkg.Meta,
+		Class: errorClass(err),
+		Error: err.Error(),
+	}
+	if group, ok := mirrorRegisters[pkg.Repo]; ok && pkg.Path != "" {
+		f.Mirrors = group.Bases()
+	}
+	c.Recorder.RecordFailure(f)
+}
+
+// Retry 重新处理尝试次数小于maxAttempts的失败记录，ctx取消后不再提交
+// 未注册的源按失败记录中保存的镜像注册，没有保存镜像时不提交任何记录并返回缺少的源
+func (c *Collector) Retry(ctx context.Context, maxAttempts int, do func(*discovery.Package)) error {
+	var missing []string
+	if err := c.Recorder.Failures(func(f *recorder.Failure) error {
+		if f.Path == "" {
+			return nil
+		}
+		if _, ok := mirrorRegisters[f.Repo]; ok {
+			return nil
+		}
+		if len(f.Mirrors) > 0 {
+			mirrorRegisters[f.Repo] = mirror.NewGroup(f.Mirrors...)
+			return nil
+		}
+		missing = append(missing, f.Repo)
+		return nil
+	}); err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return errors.Errorf("no mirrors registered for %s, retry with the -config or -type used for the crawl",
+			strings.Join(slices.Compact(missing), ", "))
+	}
+
+	return c.Recorder.Failures(func(f *recorder.Failure) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if maxAttempts > 0 && f.Attempts >= maxAttempts {
+			log.Printf("skip %s, failed %d times\n", f.URL, f.Attempts)
+			return nil
+		}
+		pkg := &discovery.Package{URL: f.URL, Repo: f.Repo, Path: f.Path, File: f.File, Meta: f.Meta}
+		atomic.AddInt64(&c.stats.Discovered, 1)
+		c.pool.Go(func() {
+			do(pkg)
+		})
+		return nil
+	})
+}
@@ -0,0 +1,99 @@
+package collector
+
+import (
+	"context"
+	"get_package_md5/image"
+	"get_package_md5/model"
+	"get_package_md5/parser"
+	"get_package_md5/utils"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// fileNameReplacer 版本中的epoch(1:2.3)等字符不适合作为文件名
+var fileNameReplacer = strings.NewReplacer(":", "_", "/", "_", "\\", "_")
+
+// CollectImage 分析本地的OCI layout目录或docker save生成的tar中的镜像
+// 每个已安装的包输出一个与对应解析器相同的文档，镜像的层及不属于任何包的ELF文件保存在image.json中
+func (c *Collector) CollectImage(ctx context.Context, p string) error {
+	images, closer, err := image.Load(p)
+	if err != nil {
+		return errors.WithMessagef(err, "load image %s", p)
+	}
+	defer closer.Close()
+
+	for _, img := range images {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		atomic.AddInt64(&c.stats.Discovered, 1)
+		if err := c.collectImage(ctx, img); err != nil {
+			if ctx.Err() != nil {
+				atomic.AddInt64(&c.stats.Cancelled, 1)
+				return ctx.Err()
+			}
+			atomic.AddInt64(&c.stats.Failed, 1)
+			log.Printf("collect image %s(%s) failed: %s\n", img.Source.Ref, img.Source.ID, err)
+		}
+	}
+	return nil
+}
+
+func (c *Collector) collectImage(ctx context.Context, img *image.Image) error {
+	// 以镜像ID去重，同一镜像的不同tag只分析一次
+	key := path.Join("/image", strings.Replace(img.Source.ID, ":", "/", 1))
+	if c.Recorder.Exist(key) {
+		atomic.AddInt64(&c.stats.Skipped, 1)
+		log.Printf("skip analyzed image %s\n", key)
+		return nil
+	}
+	log.Printf("analyzing image %s(%s) with %d layers\n", img.Source.Ref, img.Source.ID, len(img.Layers))
+
+	fs := parser.NewRootFS()
+	if err := img.Walk(ctx, func(layer int, n string, r io.Reader) error {
+		return fs.Add(layer, img.Layers[layer].DiffID, n, r)
+	}); err != nil {
+		return err
+	}
+	source := img.Source
+	inst := fs.Packages(&model.IndexMeta{Path: key, Image: &source})
+
+	dir := filepath.Join(c.outDir, key)
+	doc := &model.ImageDoc{
+		ImageSource:  img.Source,
+		OS:           inst.OS,
+		Architecture: img.Architecture,
+		Layers:       img.Layers,
+		Unowned:      inst.Unowned,
+		Unsupported:  inst.Unsupported,
+	}
+	for _, pkg := range inst.Packages {
+		doc.Packages = append(doc.Packages, pkg.Manager+":"+pkg.Name+"="+pkg.Version)
+		out := filepath.Join(dir, pkg.Manager)
+		if err := os.MkdirAll(out, 0755); err != nil {
+			return errors.WithMessagef(err, "create dir %s", out)
+		}
+		name := fileNameReplacer.Replace(pkg.Name+"_"+pkg.Version+"_"+pkg.Arch) + ".json"
+		if err := utils.SaveJson(pkg.Doc, filepath.Join(out, name)); err != nil {
+			return errors.WithMessagef(err, "save json of %s", pkg.Name)
+		}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.WithMessagef(err, "create dir %s", dir)
+	}
+	if err := utils.SaveJson(doc, filepath.Join(dir, "image.json")); err != nil {
+		return errors.WithMessagef(err, "save json of image %s", img.Source.ID)
+	}
+
+	c.Recorder.Record(key)
+	atomic.AddInt64(&c.stats.Succeeded, 1)
+	log.Printf("analyze image %s success, %d packages, %d unowned elf files\n", key, len(inst.Packages), len(inst.Unowned))
+	return nil
+}
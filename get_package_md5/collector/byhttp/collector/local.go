@@ -0,0 +1,138 @@
+package collector
+
+import (
+	"bufio"
+	"context"
+	"get_package_md5/collector/byhttp/discovery"
+	"get_package_md5/model"
+	"get_package_md5/parser"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// ScanLocal 从本地目录或文件列表发现包，与爬取共用recorder去重、goroutine池与输出目录
+// 目录递归查找所有解析器支持的包；文件视为每行一个路径或url的列表，"-"表示标准输入，列表中的目录同样递归查找
+func (c *Collector) ScanLocal(ctx context.Context, inputs []string, do func(*discovery.Package)) {
+	for _, input := range inputs {
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("start to scanning packages from %s\n", input)
+		if err := c.scanInput(ctx, input, do); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+func (c *Collector) scanInput(ctx context.Context, input string, do func(*discovery.Package)) error {
+	if input == "-" {
+		return c.scanList(ctx, os.Stdin, do)
+	}
+	info, err := os.Stat(input)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return c.scanDir(ctx, input, do)
+	}
+	f, err := os.Open(input)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := c.scanList(ctx, f, do); err != nil {
+		return errors.WithMessagef(err, "read list %s", input)
+	}
+	return nil
+}
+
+// scanList 逐行读取列表，空行及#开头的行忽略，相对路径以当前目录为准
+func (c *Collector) scanList(ctx context.Context, r io.Reader, do func(*discovery.Package)) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.Contains(line, "://") {
+			c.submit(line, &discovery.Package{URL: line}, do)
+			continue
+		}
+		info, err := os.Stat(line)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		if info.IsDir() {
+			if err := c.scanDir(ctx, line, do); err != nil {
+				log.Println(err)
+			}
+			continue
+		}
+		if err := c.submitFile(line, do); err != nil {
+			log.Println(err)
+		}
+	}
+	return scanner.Err()
+}
+
+// scanDir 递归查找目录中的包，不跟随符号链接
+func (c *Collector) scanDir(ctx context.Context, dir string, do func(*discovery.Package)) error {
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			// 无权限等错误只跳过对应的文件或目录
+			log.Println(err)
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		return c.submitFile(p, do)
+	})
+}
+
+func (c *Collector) submitFile(p string, do func(*discovery.Package)) error {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return errors.WithMessagef(err, "resolve %s", p)
+	}
+	c.submit(abs, &discovery.Package{File: abs}, do)
+	return nil
+}
+
+// submit 有解析器支持name时提交到goroutine池
+func (c *Collector) submit(name string, pkg *discovery.Package, do func(*discovery.Package)) {
+	if findParser(name) == nil {
+		return
+	}
+	atomic.AddInt64(&c.stats.Discovered, 1)
+	c.pool.Go(func() {
+		do(pkg)
+	})
+}
+
+// parseFile 解析本地文件，没有索引中的校验值时只计算摘要
+func (c *Collector) parseFile(ctx context.Context, name string, p parser.Parser, meta *model.IndexMeta) (interface{}, error) {
+	log.Printf("parsing %s\n", name)
+
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "open %s", name)
+	}
+	defer f.Close()
+
+	return c.parse(ctx, f, name, p, meta)
+}
@@ -0,0 +1,40 @@
+package collector
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+)
+
+// Stats 本次运行的计数
+type Stats struct {
+	Discovered int64
+	Succeeded  int64
+	Skipped    int64
+	Failed     int64
+	Cancelled  int64
+}
+
+// Stats 返回当前计数的快照
+func (c *Collector) Stats() Stats {
+	return Stats{
+		Discovered: atomic.LoadInt64(&c.stats.Discovered),
+		Succeeded:  atomic.LoadInt64(&c.stats.Succeeded),
+		Skipped:    atomic.LoadInt64(&c.stats.Skipped),
+		Failed:     atomic.LoadInt64(&c.stats.Failed),
+		Cancelled:  atomic.LoadInt64(&c.stats.Cancelled),
+	}
+}
+
+// PrintSummary 输出本次运行的统计，被中断时提示如何继续
+func (c *Collector) PrintSummary(w io.Writer, interrupted bool) {
+	s := c.Stats()
+	fmt.Fprintf(w, "discovered %d, succeeded %d, skipped %d, failed %d, cancelled %d\n",
+		s.Discovered, s.Succeeded, s.Skipped, s.Failed, s.Cancelled)
+	if interrupted {
+		fmt.Fprintln(w, "crawl interrupted, run the same command again to resume, finished packages will be skipped")
+	}
+	if s.Failed > 0 {
+		fmt.Fprintln(w, "failed packages are recorded, use -mode retry to reprocess them or -mode failures to list them")
+	}
+}
@@ -0,0 +1,206 @@
+package collector
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"get_package_md5/model"
+	"hash"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrChecksumMismatch 下载内容与仓库索引中的大小或校验值不一致，可重试
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// verifyReader 在解析的同时计算下载内容的大小与摘要
+type verifyReader struct {
+	r        io.Reader
+	size     int64
+	sha256   hash.Hash
+	expected hash.Hash
+	// q1 APKINDEX中的apk-q1校验值，为control段的gzip流的sha1
+	q1   *q1Writer
+	meta *model.IndexMeta
+	// err 读取下载内容时的错误(连接中断、重置等)，用于区分传输失败与包本身无法解析
+	err error
+}
+
+func newVerifyReader(r io.Reader, meta *model.IndexMeta) *verifyReader {
+	v := &verifyReader{
+		sha256: sha256.New(),
+		meta:   meta,
+	}
+	writers := []io.Writer{v.sha256}
+	if meta.Checksum != nil {
+		switch strings.ToLower(meta.Checksum.Type) {
+		case "sha1":
+			v.expected = sha1.New()
+		case "md5":
+			v.expected = md5.New()
+		case "sha512":
+			v.expected = sha512.New()
+		case "apk-q1":
+			v.q1 = newQ1Writer()
+			writers = append(writers, v.q1)
+		}
+		if v.expected != nil {
+			writers = append(writers, v.expected)
+		}
+	}
+	v.r = io.TeeReader(r, io.MultiWriter(writers...))
+	return v
+}
+
+func (v *verifyReader) Read(p []byte) (int, error) {
+	n, err := v.r.Read(p)
+	v.size += int64(n)
+	if err != nil && err != io.EOF {
+		v.err = err
+	}
+	return n, err
+}
+
+// Verify 读完剩余内容后与索引比对，通过后将sha256摘要写入meta，Checks中记录通过的比对项
+// 解析失败时也需要调用，以区分下载内容损坏与包本身无法解析
+func (v *verifyReader) Verify() error {
+	// 解析器不一定读到结尾(如tar的结束块之后)，剩余部分也需要计入摘要
+	_, err := io.Copy(io.Discard, v)
+	q1, q1Err := "", error(nil)
+	if v.q1 != nil {
+		q1, q1Err = v.q1.finish(err)
+	}
+	if err != nil {
+		return errors.WithMessagef(err, "read rest of body")
+	}
+
+	digest := hex.EncodeToString(v.sha256.Sum(nil))
+	var checks []string
+	if v.meta.Size > 0 {
+		if v.meta.Size != v.size {
+			return errors.WithMessagef(ErrChecksumMismatch, "size %d, expected %d", v.size, v.meta.Size)
+		}
+		checks = append(checks, "size")
+	}
+	if c := v.meta.Checksum; c != nil {
+		actual := ""
+		switch {
+		case strings.ToLower(c.Type) == "sha256":
+			actual = digest
+		case v.expected != nil:
+			actual = hex.EncodeToString(v.expected.Sum(nil))
+		case v.q1 != nil:
+			if q1Err != nil {
+				return errors.WithMessagef(ErrChecksumMismatch, "apk-q1: %s", q1Err)
+			}
+			actual = q1
+		}
+		// apk-q1为base64编码，区分大小写，其他为十六进制
+		match := strings.EqualFold(actual, c.Value)
+		if v.q1 != nil {
+			match = actual == c.Value
+		}
+		if actual != "" {
+			if !match {
+				return errors.WithMessagef(ErrChecksumMismatch, "%s %s, expected %s", c.Type, actual, c.Value)
+			}
+			checks = append(checks, strings.ToLower(c.Type))
+			v.meta.Verified = true
+		}
+	}
+	v.meta.Checks = checks
+	v.meta.Digest = &model.Checksum{Type: "sha256", Value: digest}
+
+	return nil
+}
+
+// q1Writer 计算apk的Q1校验值，apk由签名、control、数据三段gzip流拼接而成，未签名的包没有签名段
+// 需要解压才能找到各段的边界，因此在单独的goroutine中读取写入的内容
+type q1Writer struct {
+	pw   *io.PipeWriter
+	done chan struct{}
+	sum  string
+	err  error
+}
+
+func newQ1Writer() *q1Writer {
+	pr, pw := io.Pipe()
+	w := &q1Writer{pw: pw, done: make(chan struct{})}
+	go func() {
+		defer close(w.done)
+		w.sum, w.err = apkQ1(pr)
+		// 读取剩余内容，避免Write阻塞
+		_, _ = io.Copy(io.Discard, pr)
+	}()
+	return w
+}
+
+func (w *q1Writer) Write(p []byte) (int, error) {
+	// 计算失败时goroutine仍会读完剩余内容，这里的错误只可能来自finish之后的写入
+	n, _ := w.pw.Write(p)
+	return n, nil
+}
+
+// finish 结束写入并返回"Q1"加base64编码的sha1，err不为nil时表示内容不完整
+func (w *q1Writer) finish(err error) (string, error) {
+	w.pw.CloseWithError(err)
+	<-w.done
+	return w.sum, w.err
+}
+
+// apkQ1 读取开头的gzip流，第一个流中的文件为.SIGN.*时为签名段，control段为下一个流
+func apkQ1(r io.Reader) (string, error) {
+	// gzip.Reader的输入实现io.ByteReader时不会多读，hr只记录当前流的原始内容
+	hr := &hashByteReader{r: bufio.NewReader(r), h: sha1.New()}
+	zr, err := gzip.NewReader(hr)
+	if err != nil {
+		return "", err
+	}
+	zr.Multistream(false)
+	hdr, err := tar.NewReader(zr).Next()
+	if err != nil {
+		return "", errors.WithMessagef(err, "read first segment")
+	}
+	if _, err := io.Copy(io.Discard, zr); err != nil {
+		return "", err
+	}
+	if strings.HasPrefix(hdr.Name, ".SIGN.") {
+		hr.h.Reset()
+		if err := zr.Reset(hr); err != nil {
+			return "", errors.WithMessagef(err, "read control segment")
+		}
+		zr.Multistream(false)
+		if _, err := io.Copy(io.Discard, zr); err != nil {
+			return "", err
+		}
+	}
+	return "Q1" + base64.StdEncoding.EncodeToString(hr.h.Sum(nil)), nil
+}
+
+// hashByteReader 逐字节读取并计算摘要
+type hashByteReader struct {
+	r *bufio.Reader
+	h hash.Hash
+}
+
+func (r *hashByteReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.h.Write(p[:n])
+	return n, err
+}
+
+func (r *hashByteReader) ReadByte() (byte, error) {
+	b, err := r.r.ReadByte()
+	if err == nil {
+		r.h.Write([]byte{b})
+	}
+	return b, err
+}
@@ -0,0 +1,213 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Config 爬取配置，零值字段使用命令行参数的值
+type Config struct {
+	Output        string    `yaml:"output"`
+	Cache         string    `yaml:"cache"`
+	Concurrency   int       `yaml:"concurrency"`
+	Rate          float64   `yaml:"rate"`
+	Burst         int       `yaml:"burst"`
+	MaxMemberSize int64     `yaml:"max_member_size"` // 包内单个文件的大小上限(MB)
+	Sources       []*Source `yaml:"sources"`
+}
+
+// Source 一个发行版仓库
+// deb: Suites为dists下的suite，Components为组件，为空时使用Release中声明的值
+// apk: Suites为分支(v3.19、edge)，Components为仓库(main、community)
+// rpm: 使用Repos，Repos中未指定OS时使用Source的OS
+// ipk: Suites为OpenWrt版本(23.05.3、snapshots)，Components为feed(base、packages、luci)，
+// Architectures为包架构(x86_64、mips_24kc)，Targets为targets下的<target>/<subtarget>
+// pacman: 使用Repos，ID为仓库名(core、extra)，Dir为<仓库名>.db.tar.gz所在目录
+type Source struct {
+	Name          string    `yaml:"name"`
+	Type          string    `yaml:"type"`
+	OS            string    `yaml:"os"`
+	Mirrors       []string  `yaml:"mirrors"`
+	Suites        []string  `yaml:"suites"`
+	Components    []string  `yaml:"components"`
+	Architectures []string  `yaml:"architectures"`
+	Repos         []RpmRepo `yaml:"repos"`
+	Targets       []string  `yaml:"targets"`
+	// Include、Exclude 匹配包相对镜像根目录的路径或文件名的glob
+	Include     []string `yaml:"include"`
+	Exclude     []string `yaml:"exclude"`
+	Concurrency int      `yaml:"concurrency"`
+	Rate        float64  `yaml:"rate"`
+	Burst       int      `yaml:"burst"`
+}
+
+type RpmRepo struct {
+	ID  string `yaml:"id"`
+	Dir string `yaml:"dir"`
+	OS  string `yaml:"os"`
+}
+
+// Load 读取并校验配置文件
+func Load(p string) (*Config, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "open config %s", p)
+	}
+	defer f.Close()
+
+	c := new(Config)
+	dec := yaml.NewDecoder(f)
+	// 拼错的字段直接报错，避免配置被静默忽略
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil {
+		return nil, errors.WithMessagef(err, "decode config %s", p)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, errors.WithMessagef(err, "invalid config %s", p)
+	}
+	return c, nil
+}
+
+// Validate 校验所有配置项，返回所有问题而不是遇到第一个就停止
+func (c *Config) Validate() error {
+	var problems []string
+	addf := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if c.Concurrency < 0 {
+		addf("concurrency must not be negative")
+	}
+	if c.MaxMemberSize < 0 {
+		addf("max_member_size must not be negative")
+	}
+	if c.Rate < 0 || c.Burst < 0 {
+		addf("rate and burst must not be negative")
+	}
+	if len(c.Sources) == 0 {
+		addf("no sources")
+	}
+
+	names := map[string]bool{}
+	for i, s := range c.Sources {
+		prefix := fmt.Sprintf("sources[%d]", i)
+		if s.Name == "" {
+			addf("%s: name is required", prefix)
+		} else {
+			prefix = fmt.Sprintf("%s(%s)", prefix, s.Name)
+			if names[s.Name] {
+				addf("%s: duplicate name", prefix)
+			}
+			names[s.Name] = true
+		}
+		for _, p := range s.problems() {
+			addf("%s: %s", prefix, p)
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func (s *Source) problems() []string {
+	var problems []string
+	addf := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if len(s.Mirrors) == 0 {
+		addf("at least one mirror is required")
+	}
+	for _, m := range s.Mirrors {
+		u, err := url.Parse(m)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			addf("invalid mirror %q", m)
+		}
+	}
+
+	switch s.Type {
+	case "deb":
+		if len(s.Suites) == 0 {
+			addf("deb source needs suites")
+		}
+	case "apk":
+		if len(s.Suites) == 0 || len(s.Components) == 0 || len(s.Architectures) == 0 {
+			addf("apk source needs suites, components and architectures")
+		}
+	case "rpm":
+		if len(s.Repos) == 0 {
+			addf("rpm source needs repos")
+		}
+		for j, r := range s.Repos {
+			if r.Dir == "" {
+				addf("repos[%d]: dir is required", j)
+			}
+			if r.OS == "" && s.OS == "" {
+				addf("repos[%d]: os is required", j)
+			}
+		}
+	case "ipk":
+		if len(s.Suites) == 0 {
+			addf("ipk source needs suites")
+		}
+		if (len(s.Components) == 0 || len(s.Architectures) == 0) && len(s.Targets) == 0 {
+			addf("ipk source needs components and architectures, or targets")
+		}
+	case "pacman":
+		if len(s.Repos) == 0 {
+			addf("pacman source needs repos")
+		}
+		for j, r := range s.Repos {
+			if r.ID == "" || r.Dir == "" {
+				addf("repos[%d]: id and dir are required", j)
+			}
+		}
+	case "":
+		addf("type is required")
+	default:
+		addf("unknown type %q, expect apk, deb, rpm, ipk or pacman", s.Type)
+	}
+
+	for _, g := range append(append([]string{}, s.Include...), s.Exclude...) {
+		if _, err := path.Match(g, ""); err != nil {
+			addf("invalid glob %q", g)
+		}
+	}
+	if s.Concurrency < 0 {
+		addf("concurrency must not be negative")
+	}
+	if s.Rate < 0 || s.Burst < 0 {
+		addf("rate and burst must not be negative")
+	}
+
+	return problems
+}
+
+// Match 判断相对路径p是否满足include、exclude规则
+func (s *Source) Match(p string) bool {
+	if len(s.Include) > 0 && !matchAny(s.Include, p) {
+		return false
+	}
+	return !matchAny(s.Exclude, p)
+}
+
+func matchAny(globs []string, p string) bool {
+	base := path.Base(p)
+	for _, g := range globs {
+		if ok, _ := path.Match(g, p); ok {
+			return true
+		}
+		if ok, _ := path.Match(g, base); ok {
+			return true
+		}
+	}
+	return false
+}
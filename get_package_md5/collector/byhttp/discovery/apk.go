@@ -0,0 +1,111 @@
+package discovery
+
+import (
+	"bufio"
+	"context"
+	"get_package_md5/model"
+	"get_package_md5/unarchiver"
+	"io"
+	"log"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ApkIndex 通过<branch>/<repo>/<arch>/APKINDEX.tar.gz发现alpine包
+type ApkIndex struct {
+	Branches []string
+	Repos    []string
+	Arches   []string
+}
+
+func (a *ApkIndex) Discover(ctx context.Context, fetch Fetch, do func(pkg *Package)) error {
+	for _, branch := range a.Branches {
+		for _, repo := range a.Repos {
+			for _, arch := range a.Arches {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				dir := path.Join(branch, repo, arch)
+				if err := a.discoverIndex(fetch, dir, branch, repo, do); err != nil {
+					// 部分分支没有某些仓库或架构，跳过即可
+					log.Println(err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (a *ApkIndex) discoverIndex(fetch Fetch, dir, branch, repo string, do func(pkg *Package)) error {
+	index := path.Join(dir, "APKINDEX.tar.gz")
+	body, err := fetch(index)
+	if err != nil {
+		return errors.WithMessagef(err, "fetch %s", index)
+	}
+	defer body.Close()
+
+	osName := "alpine " + strings.TrimPrefix(branch, "v")
+	if err := unarchiver.ReadTarGzip(body, func(n string, r io.Reader) error {
+		if n != "APKINDEX" {
+			return nil
+		}
+		return parseApkIndex(r, func(meta *model.IndexMeta) {
+			meta.OS = osName
+			meta.Suite = branch
+			meta.Repository = repo
+			do(&Package{
+				Path: path.Join(dir, meta.Name+"-"+meta.Version+".apk"),
+				Meta: meta,
+			})
+		})
+	}); err != nil {
+		return errors.WithMessagef(err, "read %s", index)
+	}
+	return nil
+}
+
+// parseApkIndex 解析APKINDEX，记录之间以空行分隔，每行为"K:value"
+func parseApkIndex(r io.Reader, do func(meta *model.IndexMeta)) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	meta := new(model.IndexMeta)
+	flush := func() {
+		if meta.Name != "" && meta.Version != "" {
+			do(meta)
+		}
+		meta = new(model.IndexMeta)
+	}
+
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" {
+			flush()
+			continue
+		}
+		if len(line) < 2 || line[1] != ':' {
+			continue
+		}
+		val := line[2:]
+		switch line[0] {
+		case 'P':
+			meta.Name = val
+		case 'V':
+			meta.Version = val
+		case 'A':
+			meta.Architecture = val
+		case 'C':
+			meta.Checksum = &model.Checksum{Type: "apk-q1", Value: val}
+		case 'S':
+			meta.Size, _ = strconv.ParseInt(val, 10, 64)
+		case 'o':
+			meta.Origin = val
+		}
+	}
+	flush()
+
+	return sc.Err()
+}
@@ -0,0 +1,187 @@
+package discovery
+
+import (
+	"context"
+	"get_package_md5/model"
+	"get_package_md5/parser"
+	"get_package_md5/unarchiver"
+	"io"
+	"log"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DebIndex 通过dists/<suite>/Release以及<component>/binary-<arch>/Packages发现deb包
+// Components、Arches为空时使用Release文件中声明的值
+type DebIndex struct {
+	Suites     []string
+	Components []string
+	Arches     []string
+}
+
+// release dists/<suite>/Release中需要的字段
+type release struct {
+	Origin        string
+	Version       string
+	Codename      string
+	Components    []string
+	Architectures []string
+}
+
+func (d *DebIndex) Discover(ctx context.Context, fetch Fetch, do func(pkg *Package)) error {
+	// 同一个pool文件会出现在多个suite以及arch为all的多个架构索引中
+	seen := map[string]bool{}
+
+	for _, suite := range d.Suites {
+		rel, err := d.fetchRelease(fetch, suite)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		osName := releaseOS(rel, suite)
+
+		components := d.Components
+		if len(components) == 0 {
+			components = rel.Components
+		}
+		arches := d.Arches
+		if len(arches) == 0 {
+			arches = rel.Architectures
+		}
+
+		for _, component := range components {
+			for _, arch := range arches {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				err := d.discoverPackages(ctx, fetch, suite, component, arch, func(meta *model.IndexMeta, filename string) {
+					if seen[filename] {
+						return
+					}
+					seen[filename] = true
+					meta.OS = osName
+					meta.Suite = suite
+					meta.Repository = component
+					do(&Package{
+						Path: filename,
+						Meta: meta,
+					})
+				})
+				if err != nil {
+					log.Println(err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (d *DebIndex) fetchRelease(fetch Fetch, suite string) (*release, error) {
+	u := path.Join("dists", suite, "Release")
+	body, err := fetch(u)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "fetch %s", u)
+	}
+	defer body.Close()
+
+	rel := new(release)
+	if err := parser.ReadStanzas(body, func(s *parser.Stanza) bool {
+		rel.Origin = s.Get("Origin")
+		rel.Version = s.Get("Version")
+		rel.Codename = s.Get("Codename")
+		rel.Components = strings.Fields(s.Get("Components"))
+		rel.Architectures = strings.Fields(s.Get("Architectures"))
+		return false
+	}); err != nil {
+		return nil, errors.WithMessagef(err, "read %s", u)
+	}
+	return rel, nil
+}
+
+func (d *DebIndex) discoverPackages(ctx context.Context, fetch Fetch, suite, component, arch string, do func(meta *model.IndexMeta, filename string)) error {
+	dir := path.Join("dists", suite, component, "binary-"+arch)
+	body, err := fetchPackages(fetch, dir)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	if err := parser.ReadStanzas(body, func(s *parser.Stanza) bool {
+		if ctx.Err() != nil {
+			return false
+		}
+		filename := s.Get("Filename")
+		if filename == "" {
+			return true
+		}
+		meta := &model.IndexMeta{
+			Name:         s.Get("Package"),
+			Version:      s.Get("Version"),
+			Architecture: s.Get("Architecture"),
+			Origin:       s.Get("Source"),
+		}
+		meta.Size, _ = strconv.ParseInt(s.Get("Size"), 10, 64)
+		if sum := s.Get("SHA256"); sum != "" {
+			meta.Checksum = &model.Checksum{Type: "sha256", Value: sum}
+		} else if sum := s.Get("MD5sum"); sum != "" {
+			meta.Checksum = &model.Checksum{Type: "md5", Value: sum}
+		}
+		do(meta, filename)
+		return true
+	}); err != nil {
+		return errors.WithMessagef(err, "read packages of %s", dir)
+	}
+	return nil
+}
+
+// fetchPackages 依次尝试Packages.xz、Packages.gz以及未压缩的Packages
+func fetchPackages(fetch Fetch, dir string) (io.ReadCloser, error) {
+	return fetchIndex(fetch, dir, "Packages.xz", "Packages.gz", "Packages")
+}
+
+// fetchIndex 依次尝试dir下的names，返回第一个获取成功的文件解压后的内容
+func fetchIndex(fetch Fetch, dir string, names ...string) (io.ReadCloser, error) {
+	var lastErr error
+	for _, name := range names {
+		u := path.Join(dir, name)
+		body, err := fetch(u)
+		if err != nil {
+			lastErr = errors.WithMessagef(err, "fetch %s", u)
+			continue
+		}
+		r, err := unarchiver.Decompress(body)
+		if err != nil {
+			body.Close()
+			return nil, errors.WithMessagef(err, "open %s", u)
+		}
+		return readCloser{r, body}, nil
+	}
+	return nil, lastErr
+}
+
+// releaseOS 由Release中的Origin与Version拼出"ubuntu 22.04"形式的系统版本
+func releaseOS(rel *release, suite string) string {
+	family := strings.ToLower(rel.Origin)
+	ver := rel.Version
+	if ver == "" {
+		ver = rel.Codename
+	}
+	if ver == "" {
+		ver = suite
+	}
+	return strings.TrimSpace(family + " " + ver)
+}
+
+// readCloser 关闭时依次关闭解压器与原始的body
+type readCloser struct {
+	io.ReadCloser
+	body io.Closer
+}
+
+func (r readCloser) Close() error {
+	r.ReadCloser.Close()
+	return r.body.Close()
+}
@@ -0,0 +1,55 @@
+package discovery
+
+import (
+	"context"
+	"get_package_md5/model"
+	"io"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Package 待下载的包
+type Package struct {
+	// URL 完整下载地址，仅在不经过镜像组下载时使用
+	URL string
+	// Repo 注册的发行版名称，决定使用哪一组镜像
+	Repo string
+	// Path 相对镜像根目录的路径，由collector选择镜像下载
+	Path string
+	// File 本地文件的绝对路径，不为空时直接读取，不经过网络
+	File string
+	Meta *model.IndexMeta
+}
+
+// Key 与镜像无关的唯一标识，用作recorder的key以及输出目录
+func (p *Package) Key() string {
+	if p.File != "" {
+		return path.Join("/local", filepath.ToSlash(p.File))
+	}
+	if p.Path != "" {
+		return path.Join("/", p.Repo, p.Path)
+	}
+	if u, err := url.Parse(p.URL); err == nil {
+		return u.Path
+	}
+	return p.URL
+}
+
+// Fetch 获取相对镜像根目录的文件内容，由collector提供，调用方负责关闭
+type Fetch func(p string) (io.ReadCloser, error)
+
+// Source 基于仓库索引的包发现源
+type Source interface {
+	// Discover 逐个回调发现的包，ctx取消后尽快返回
+	Discover(ctx context.Context, fetch Fetch, do func(pkg *Package)) error
+}
+
+func join(base string, elems ...string) string {
+	u := strings.TrimSuffix(base, "/")
+	for _, e := range elems {
+		u = u + "/" + strings.Trim(e, "/")
+	}
+	return u
+}
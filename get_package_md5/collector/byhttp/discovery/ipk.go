@@ -0,0 +1,103 @@
+package discovery
+
+import (
+	"context"
+	"get_package_md5/model"
+	"get_package_md5/parser"
+	"log"
+	"path"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// IpkIndex 通过opkg的Packages.gz发现OpenWrt的ipk包
+// 软件包位于<release>/packages/<arch>/<feed>/，内核模块及基础包位于<release>/targets/<target>/packages/
+// Releases为版本号(23.05.3)或snapshots，Targets形如x86/64、ramips/mt7621
+type IpkIndex struct {
+	Releases []string
+	Feeds    []string
+	Arches   []string
+	Targets  []string
+}
+
+func (i *IpkIndex) Discover(ctx context.Context, fetch Fetch, do func(pkg *Package)) error {
+	for _, rel := range i.Releases {
+		base := path.Join("releases", rel)
+		if rel == "snapshots" {
+			base = rel
+		}
+		osName := "openwrt " + rel
+
+		var feeds []ipkFeed
+		for _, arch := range i.Arches {
+			for _, feed := range i.Feeds {
+				feeds = append(feeds, ipkFeed{dir: path.Join(base, "packages", arch, feed), repo: feed})
+			}
+		}
+		for _, target := range i.Targets {
+			feeds = append(feeds, ipkFeed{dir: path.Join(base, "targets", target, "packages"), repo: target})
+		}
+
+		for _, feed := range feeds {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err := discoverIpkFeed(ctx, fetch, feed.dir, func(meta *model.IndexMeta, filename string) {
+				meta.OS = osName
+				meta.Suite = rel
+				meta.Repository = feed.repo
+				do(&Package{
+					Path: path.Join(feed.dir, filename),
+					Meta: meta,
+				})
+			}); err != nil {
+				// 部分版本没有某些feed或target，跳过即可
+				log.Println(err)
+			}
+		}
+	}
+	return nil
+}
+
+// ipkFeed 一个Packages索引所在的目录，repo为feed名或target
+type ipkFeed struct {
+	dir  string
+	repo string
+}
+
+// discoverIpkFeed 解析feed中的Packages，Filename为相对feed目录的文件名
+func discoverIpkFeed(ctx context.Context, fetch Fetch, dir string, do func(meta *model.IndexMeta, filename string)) error {
+	body, err := fetchIndex(fetch, dir, "Packages.gz", "Packages")
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	if err := parser.ReadStanzas(body, func(s *parser.Stanza) bool {
+		if ctx.Err() != nil {
+			return false
+		}
+		filename := s.Get("Filename")
+		if filename == "" {
+			return true
+		}
+		meta := &model.IndexMeta{
+			Name:         s.Get("Package"),
+			Version:      s.Get("Version"),
+			Architecture: s.Get("Architecture"),
+			Origin:       s.Get("SourceName"),
+		}
+		meta.Size, _ = strconv.ParseInt(s.Get("Size"), 10, 64)
+		if sum := s.Get("SHA256sum"); sum != "" {
+			meta.Checksum = &model.Checksum{Type: "sha256", Value: sum}
+		} else if sum := s.Get("MD5Sum"); sum != "" {
+			meta.Checksum = &model.Checksum{Type: "md5", Value: sum}
+		}
+		do(meta, filename)
+		return true
+	}); err != nil {
+		return errors.WithMessagef(err, "read packages of %s", dir)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package discovery
+
+import (
+	"bufio"
+	"context"
+	"get_package_md5/model"
+	"get_package_md5/unarchiver"
+	"io"
+	"log"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// PacmanRepo 一个pacman仓库，Name为仓库名(core、extra、multilib)，Dir为<Name>.db所在目录
+// Arch Linux为<repo>/os/<arch>/，Manjaro为<branch>/<repo>/<arch>/
+type PacmanRepo struct {
+	Name string
+	Dir  string
+	OS   string
+}
+
+// PacmanIndex 通过仓库的<repo>.db发现pacman包
+type PacmanIndex struct {
+	Repos []PacmanRepo
+}
+
+func (i *PacmanIndex) Discover(ctx context.Context, fetch Fetch, do func(pkg *Package)) error {
+	for _, repo := range i.Repos {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := discoverPacmanRepo(fetch, repo, do); err != nil {
+			log.Println(err)
+		}
+	}
+	return nil
+}
+
+func discoverPacmanRepo(fetch Fetch, repo PacmanRepo, do func(pkg *Package)) error {
+	// <repo>.db为指向<repo>.db.tar.gz的链接，部分镜像不保留链接，只提供后者
+	index := path.Join(repo.Dir, repo.Name+".db.tar.gz")
+	body, err := fetch(index)
+	if err != nil {
+		return errors.WithMessagef(err, "fetch %s", index)
+	}
+	defer body.Close()
+
+	// repo-add可以生成gzip、xz、zstd等压缩的db，后缀不一定与之对应
+	if err := unarchiver.ReadTarAuto(body, func(n string, r io.Reader) error {
+		if path.Base(n) != "desc" {
+			return nil
+		}
+		meta, filename, err := parsePacmanDesc(r)
+		if err != nil {
+			return errors.WithMessagef(err, "parse %s", n)
+		}
+		if filename == "" {
+			return nil
+		}
+		meta.OS = repo.OS
+		meta.Repository = repo.Name
+		do(&Package{
+			Path: path.Join(repo.Dir, filename),
+			Meta: meta,
+		})
+		return nil
+	}); err != nil {
+		return errors.WithMessagef(err, "read %s", index)
+	}
+	return nil
+}
+
+// parsePacmanDesc 解析仓库db中每个包的desc文件，字段以%NAME%形式的行开头，值占之后的若干行，以空行结束
+func parsePacmanDesc(r io.Reader) (*model.IndexMeta, string, error) {
+	meta := new(model.IndexMeta)
+	var filename, section string
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			section = ""
+			continue
+		}
+		if strings.HasPrefix(line, "%") && strings.HasSuffix(line, "%") {
+			section = line
+			continue
+		}
+		switch section {
+		case "%FILENAME%":
+			filename = line
+		case "%NAME%":
+			meta.Name = line
+		case "%VERSION%":
+			meta.Version = line
+		case "%BASE%":
+			meta.Origin = line
+		case "%ARCH%":
+			meta.Architecture = line
+		case "%CSIZE%":
+			meta.Size, _ = strconv.ParseInt(line, 10, 64)
+		case "%SHA256SUM%":
+			meta.Checksum = &model.Checksum{Type: "sha256", Value: line}
+		case "%MD5SUM%":
+			if meta.Checksum == nil {
+				meta.Checksum = &model.Checksum{Type: "md5", Value: line}
+			}
+		}
+	}
+	return meta, filename, sc.Err()
+}
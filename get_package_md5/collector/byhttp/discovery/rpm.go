@@ -0,0 +1,178 @@
+package discovery
+
+import (
+	"context"
+	"encoding/xml"
+	"get_package_md5/model"
+	"get_package_md5/unarchiver"
+	"io"
+	"log"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// RpmRepo 一个yum/dnf仓库，Dir为repodata所在目录(相对镜像根目录)，OS形如"rocky 9"
+type RpmRepo struct {
+	ID  string
+	Dir string
+	OS  string
+}
+
+// RpmIndex 通过repodata/repomd.xml以及primary.xml发现rpm包
+type RpmIndex struct {
+	Repos []RpmRepo
+}
+
+type repomd struct {
+	Data []struct {
+		Type     string `xml:"type,attr"`
+		Location struct {
+			Href string `xml:"href,attr"`
+		} `xml:"location"`
+	} `xml:"data"`
+}
+
+type primaryPackage struct {
+	Type    string `xml:"type,attr"`
+	Name    string `xml:"name"`
+	Arch    string `xml:"arch"`
+	Version struct {
+		Epoch string `xml:"epoch,attr"`
+		Ver   string `xml:"ver,attr"`
+		Rel   string `xml:"rel,attr"`
+	} `xml:"version"`
+	Checksum struct {
+		Type  string `xml:"type,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"checksum"`
+	Size struct {
+		Package int64 `xml:"package,attr"`
+	} `xml:"size"`
+	Location struct {
+		Base string `xml:"base,attr"`
+		Href string `xml:"href,attr"`
+	} `xml:"location"`
+	Format struct {
+		SourceRpm string `xml:"sourcerpm"`
+	} `xml:"format"`
+}
+
+func (i *RpmIndex) Discover(ctx context.Context, fetch Fetch, do func(pkg *Package)) error {
+	for _, repo := range i.Repos {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := discoverRpmRepo(fetch, repo, do); err != nil {
+			log.Println(err)
+		}
+	}
+	return nil
+}
+
+func discoverRpmRepo(fetch Fetch, repo RpmRepo, do func(pkg *Package)) error {
+	primary, err := primaryLocation(fetch, repo.Dir)
+	if err != nil {
+		return err
+	}
+
+	u := path.Join(repo.Dir, primary)
+	body, err := fetch(u)
+	if err != nil {
+		return errors.WithMessagef(err, "fetch %s", u)
+	}
+	defer body.Close()
+
+	r, err := unarchiver.Decompress(body)
+	if err != nil {
+		return errors.WithMessagef(err, "open %s", u)
+	}
+	defer r.Close()
+
+	if err := readPrimary(r, func(p *primaryPackage) {
+		pkg := &Package{Meta: primaryMeta(p, repo)}
+		// xml:base指定了包的完整地址时不经过镜像组
+		if strings.Contains(p.Location.Base, "://") {
+			pkg.URL = join(p.Location.Base, p.Location.Href)
+		} else {
+			pkg.Path = path.Join(repo.Dir, p.Location.Base, p.Location.Href)
+		}
+		do(pkg)
+	}); err != nil {
+		return errors.WithMessagef(err, "read %s", u)
+	}
+	return nil
+}
+
+// primaryLocation 从repomd.xml中找到primary数据的相对路径
+func primaryLocation(fetch Fetch, dir string) (string, error) {
+	u := path.Join(dir, "repodata/repomd.xml")
+	body, err := fetch(u)
+	if err != nil {
+		return "", errors.WithMessagef(err, "fetch %s", u)
+	}
+	defer body.Close()
+
+	var md repomd
+	if err := xml.NewDecoder(body).Decode(&md); err != nil {
+		return "", errors.WithMessagef(err, "decode %s", u)
+	}
+	for _, d := range md.Data {
+		if d.Type == "primary" {
+			return d.Location.Href, nil
+		}
+	}
+	return "", errors.Errorf("no primary data in %s", u)
+}
+
+// readPrimary 流式解析primary.xml，避免一次性载入整个文件
+func readPrimary(r io.Reader, do func(p *primaryPackage)) error {
+	dec := xml.NewDecoder(r)
+	for {
+		t, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		se, ok := t.(xml.StartElement)
+		if !ok || se.Name.Local != "package" {
+			continue
+		}
+		p := new(primaryPackage)
+		if err := dec.DecodeElement(p, &se); err != nil {
+			return err
+		}
+		if p.Type != "rpm" || p.Location.Href == "" {
+			continue
+		}
+		do(p)
+	}
+}
+
+func primaryMeta(p *primaryPackage, repo RpmRepo) *model.IndexMeta {
+	ver := p.Version.Ver + "-" + p.Version.Rel
+	if p.Version.Epoch != "" && p.Version.Epoch != "0" {
+		ver = p.Version.Epoch + ":" + ver
+	}
+	meta := &model.IndexMeta{
+		OS:           repo.OS,
+		Repository:   repo.ID,
+		Name:         p.Name,
+		Version:      ver,
+		Architecture: p.Arch,
+		Origin:       p.Format.SourceRpm,
+		Size:         p.Size.Package,
+	}
+	if p.Checksum.Value != "" {
+		typ := p.Checksum.Type
+		// 旧版createrepo使用"sha"表示sha1
+		if typ == "sha" {
+			typ = "sha1"
+		}
+		meta.Checksum = &model.Checksum{Type: typ, Value: strings.TrimSpace(p.Checksum.Value)}
+	}
+	return meta
+}
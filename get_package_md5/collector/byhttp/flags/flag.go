@@ -3,6 +3,7 @@ package flags
 import (
 	"flag"
 	"strings"
+	"time"
 )
 
 var (
@@ -10,17 +11,45 @@ var (
 	Limit    int
 	Out      string
 	Cache    string
+	Rate     float64
+	Burst    int
+	// Mode 运行模式：crawl爬取、retry重试失败记录、failures导出失败记录、image分析本地镜像、local解析本地文件
+	Mode        string
+	MaxAttempts int
+	// Config 配置文件路径，指定后忽略-pkg参数
+	Config string
+	// Grace 收到中断信号后等待进行中的下载完成的时间
+	Grace time.Duration
+	// MaxMember 包内单个文件的大小上限(MB)
+	MaxMember int64
+	// Images image模式下分析的OCI layout目录或docker save生成的tar
+	Images []string
+	// Inputs local模式下的本地目录或文件列表
+	Inputs []string
 )
 
 func LoadFlags() {
-	var list string
-	flag.StringVar(&list, "pkg", "", "指定爬取类型(alpine、centos、ubuntu、debian)，用逗号分开，默认全部爬取")
+	var list, images, inputs string
+	flag.StringVar(&list, "pkg", "", "指定爬取类型(alpine、centos、rocky、almalinux、fedora、openeuler、ubuntu、debian、archlinux、manjaro、openwrt)，用逗号分开，默认全部爬取")
 	flag.IntVar(&Limit, "l", 8, "协程数限制")
 	flag.StringVar(&Out, "o", "./", "结果保存位置")
 	flag.StringVar(&Cache, "c", "./cache", "下载缓存目录")
+	flag.Float64Var(&Rate, "rate", 5, "每个host每秒请求数，0表示不限速")
+	flag.IntVar(&Burst, "burst", 10, "每个host允许的突发请求数")
+	flag.StringVar(&Mode, "mode", "crawl", "运行模式(crawl、retry、failures、image、local)，failures以json行的形式导出失败记录，image分析-image指定的本地镜像，local解析-input指定的本地文件")
+	flag.StringVar(&Config, "config", "", "yaml配置文件，指定后按配置注册源，忽略-pkg")
+	flag.IntVar(&MaxAttempts, "max-attempts", 5, "retry模式下失败次数达到该值的记录不再重试，0表示不限制")
+	flag.DurationVar(&Grace, "grace", 30*time.Second, "收到中断信号后等待进行中的下载完成的时间，超时后强制取消")
+	flag.Int64Var(&MaxMember, "max-member", 512, "包内单个文件的大小上限(MB)，超过的ELF文件不计算hash，0表示不限制")
+
+	flag.StringVar(&images, "image", "", "image模式下分析的OCI layout目录或docker save生成的tar，用逗号分开")
+	flag.StringVar(&inputs, "input", "", "local模式下递归查找包的目录，或每行一个路径或url的列表文件(-表示标准输入)，用逗号分开")
 
 	flag.Parse()
 
+	Images = splitList(images)
+	Inputs = splitList(inputs)
+
 	if list == "" {
 		TypeList = []string{"alpine", "centos", "ubuntu", "debian"}
 	} else {
@@ -33,3 +62,14 @@ func LoadFlags() {
 		}
 	}
 }
+
+// splitList 按逗号分开，忽略空项
+func splitList(s string) []string {
+	var list []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			list = append(list, p)
+		}
+	}
+	return list
+}
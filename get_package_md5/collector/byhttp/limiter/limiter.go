@@ -0,0 +1,195 @@
+package limiter
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// breakerThreshold 连续失败多少次后熔断该host
+	breakerThreshold = 5
+	// breakerCooldown 熔断后暂停访问该host的时长
+	breakerCooldown = time.Minute
+)
+
+// Limiter 按host进行令牌桶限速，目录访问与下载共用
+type Limiter struct {
+	mu    sync.Mutex
+	rate  float64
+	burst int
+	hosts map[string]*bucket
+}
+
+type bucket struct {
+	mu          sync.Mutex
+	rate        float64
+	burst       int
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+	failures    int
+	// custom 已通过SetHostRate单独设置过
+	custom bool
+}
+
+// NewLimiter 创建限速器，rate为每秒请求数，burst为桶容量
+func NewLimiter(rate float64, burst int) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &Limiter{
+		rate:  rate,
+		burst: burst,
+		hosts: map[string]*bucket{},
+	}
+}
+
+func (l *Limiter) bucket(host string) *bucket {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.hosts[host]
+	if !ok {
+		b = &bucket{rate: l.rate, burst: l.burst, tokens: float64(l.burst), last: time.Now()}
+		l.hosts[host] = b
+	}
+	return b
+}
+
+// SetHostRate 为单个host设置不同于默认值的速率与桶容量，burst小于1时沿用默认的桶容量
+// 多个源共用同一host时取其中更严格的设置，rate不大于0表示不限速
+func (l *Limiter) SetHostRate(host string, rate float64, burst int) {
+	if burst < 1 {
+		burst = l.burst
+	}
+	b := l.bucket(host)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.custom && (b.rate != rate || b.burst != burst) {
+		if b.rate > 0 && (rate <= 0 || b.rate < rate) {
+			rate = b.rate
+		}
+		if b.burst < burst {
+			burst = b.burst
+		}
+		log.Printf("conflicting rate limits for %s, use the stricter rate %g burst %d\n", host, rate, burst)
+	}
+	b.custom = true
+	b.rate = rate
+	b.burst = burst
+	if b.tokens > float64(burst) {
+		b.tokens = float64(burst)
+	}
+}
+
+// Wait 阻塞直到host未被暂停且拿到令牌，ctx取消时返回其错误
+func (l *Limiter) Wait(ctx context.Context, host string) error {
+	b := l.bucket(host)
+	for {
+		b.mu.Lock()
+		now := time.Now()
+		if now.Before(b.pausedUntil) {
+			d := b.pausedUntil.Sub(now)
+			b.mu.Unlock()
+			if err := sleep(ctx, d); err != nil {
+				return err
+			}
+			continue
+		}
+		if b.rate <= 0 {
+			b.mu.Unlock()
+			return nil
+		}
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > float64(b.burst) {
+			b.tokens = float64(b.burst)
+		}
+		b.last = now
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+			return nil
+		}
+		d := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		b.mu.Unlock()
+		if err := sleep(ctx, d); err != nil {
+			return err
+		}
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Pause 在d时间内暂停访问host，已有更长的暂停时不缩短
+func (l *Limiter) Pause(host string, d time.Duration) {
+	b := l.bucket(host)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if until := time.Now().Add(d); until.After(b.pausedUntil) {
+		b.pausedUntil = until
+	}
+}
+
+// Success 请求成功，重置连续失败计数
+func (l *Limiter) Success(host string) {
+	b := l.bucket(host)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+}
+
+// Failure 记录一次失败，连续失败达到阈值时熔断该host，返回是否已熔断
+func (l *Limiter) Failure(host string) bool {
+	b := l.bucket(host)
+	b.mu.Lock()
+	b.failures++
+	open := b.failures >= breakerThreshold
+	if open {
+		b.failures = 0
+	}
+	b.mu.Unlock()
+
+	if open {
+		l.Pause(host, breakerCooldown)
+	}
+	return open
+}
+
+// RetryAfter 解析Retry-After头，支持秒数与HTTP时间两种格式
+func RetryAfter(h http.Header) (time.Duration, bool) {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if sec, err := strconv.Atoi(v); err == nil {
+		if sec < 0 {
+			return 0, false
+		}
+		return time.Duration(sec) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
@@ -1,13 +1,23 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
+	"encoding/json"
 	"get_package_md5/collector/byhttp/collector"
+	"get_package_md5/collector/byhttp/config"
+	"get_package_md5/collector/byhttp/discovery"
 	"get_package_md5/collector/byhttp/flags"
+	"get_package_md5/collector/byhttp/limiter"
 	"get_package_md5/collector/byhttp/recorder"
 	sleeper2 "get_package_md5/collector/byhttp/sleeper"
+	"get_package_md5/utils"
+	"io"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	pool2 "github.com/sourcegraph/conc/pool"
@@ -15,7 +25,21 @@ import (
 
 func main() {
 	flags.LoadFlags()
-	collector.Register(flags.TypeList)
+	if flags.Config != "" {
+		cfg, err := config.Load(flags.Config)
+		if err != nil {
+			log.Fatal(err)
+		}
+		applyConfig(cfg)
+	} else if flags.Mode != "local" {
+		collector.Register(flags.TypeList)
+	}
+	if flags.Mode == "local" || flags.Mode == "retry" {
+		// 本地文件及失败记录中的包格式不确定，注册所有解析器
+		collector.RegisterParsers()
+	}
+
+	utils.MaxMemberSize = flags.MaxMember << 20
 
 	// 创建recorder
 	rcd, err := recorder.NewAccessRecorder(flags.Cache)
@@ -27,19 +51,108 @@ func main() {
 	// 创建goroutine池
 	pool := pool2.New().WithMaxGoroutines(flags.Limit)
 
-	// 创建sleeper防止访问过快
-	sleeper := sleeper2.NewSleeper(500*time.Microsecond, 500*time.Microsecond)
+	// 创建sleeper用于失败后的退避
+	sleeper := sleeper2.NewSleeper(time.Second, 500*time.Millisecond)
+
+	// 创建按host的限速器防止访问过快
+	lmt := limiter.NewLimiter(flags.Rate, flags.Burst)
 
 	// 创建collector
-	c := collector.NewCollector(DefaultHttpCli(), rcd, pool, sleeper, flags.Out)
+	c := collector.NewCollector(DefaultHttpCli(), rcd, pool, sleeper, lmt, flags.Out)
 
-	c.Start(func(packageUrl string) {
-		err := c.DownloadAndParse(packageUrl)
+	// 收到中断信号后停止发现新包，进行中的下载在grace时间内继续完成
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	dlCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-dlCtx.Done():
+			return
+		}
+		log.Printf("interrupted, waiting up to %s for running downloads\n", flags.Grace)
+		select {
+		case <-time.After(flags.Grace):
+			cancel()
+		case <-dlCtx.Done():
+		}
+	}()
+
+	do := func(pkg *discovery.Package) {
+		err := c.DownloadAndParse(dlCtx, pkg)
 		if err != nil {
-			c.Recorder.RecordError(err.Error())
+			c.RecordFailure(pkg, err)
 		}
-	})
+	}
+
+	switch flags.Mode {
+	case "crawl":
+		c.Start(ctx, do)
+	case "retry":
+		if err := c.Retry(ctx, flags.MaxAttempts, do); err != nil {
+			if ctx.Err() != nil {
+				log.Println(err)
+				break
+			}
+			rcd.Close()
+			log.Fatal(err)
+		}
+	case "failures":
+		if err := exportFailures(rcd, os.Stdout); err != nil {
+			log.Println(err)
+		}
+	case "local":
+		c.ScanLocal(ctx, flags.Inputs, do)
+	case "image":
+		for _, p := range flags.Images {
+			if err := c.CollectImage(dlCtx, p); err != nil {
+				log.Println(err)
+			}
+		}
+	default:
+		log.Printf("invalid mode %s\n", flags.Mode)
+	}
 	c.Wait()
+	interrupted := ctx.Err() != nil
+	cancel()
+
+	if flags.Mode != "failures" {
+		c.PrintSummary(os.Stdout, interrupted)
+	}
+}
+
+// applyConfig 注册配置文件中的源，并用配置覆盖对应的命令行参数
+func applyConfig(cfg *config.Config) {
+	for _, s := range cfg.Sources {
+		collector.RegisterConfig(s)
+	}
+	if cfg.Output != "" {
+		flags.Out = cfg.Output
+	}
+	if cfg.Cache != "" {
+		flags.Cache = cfg.Cache
+	}
+	if cfg.Concurrency > 0 {
+		flags.Limit = cfg.Concurrency
+	}
+	if cfg.Rate > 0 {
+		flags.Rate = cfg.Rate
+	}
+	if cfg.Burst > 0 {
+		flags.Burst = cfg.Burst
+	}
+	if cfg.MaxMemberSize > 0 {
+		flags.MaxMember = cfg.MaxMemberSize
+	}
+}
+
+// exportFailures 以json行的形式输出所有失败记录
+func exportFailures(rcd *recorder.AccessRecorder, w io.Writer) error {
+	enc := json.NewEncoder(w)
+	return rcd.Failures(func(f *recorder.Failure) error {
+		return enc.Encode(f)
+	})
 }
 
 func DefaultHttpCli() http.Client {
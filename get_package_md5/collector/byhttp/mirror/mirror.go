@@ -0,0 +1,125 @@
+package mirror
+
+import (
+	"math/rand"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// priorLatency 尚无测量数据时按配置顺序估计的延迟，越靠前越快
+	priorLatency = 200 * time.Millisecond
+	// ewmaWeight 新测量的延迟在平均值中的权重
+	ewmaWeight = 0.2
+)
+
+// Mirror 一个镜像站点及其健康状况
+type Mirror struct {
+	Base     string
+	latency  time.Duration
+	failures int
+}
+
+// score 越小越优先，连续失败会成倍降低优先级
+func (m *Mirror) score() float64 {
+	f := float64(m.failures + 1)
+	return float64(m.latency) * f * f
+}
+
+// Group 同一发行版的一组镜像，按配置顺序作为初始优先级
+type Group struct {
+	mu      sync.Mutex
+	mirrors []*Mirror
+}
+
+func NewGroup(bases ...string) *Group {
+	g := new(Group)
+	for i, b := range bases {
+		g.mirrors = append(g.mirrors, &Mirror{
+			Base:    strings.TrimSuffix(b, "/") + "/",
+			latency: priorLatency * time.Duration(i+1),
+		})
+	}
+	return g
+}
+
+// Candidates 返回本次下载依次尝试的镜像
+// 第一个按分数倒数加权随机选出以分摊负载，其余按分数从优到劣排列用于失败切换
+func (g *Group) Candidates() []*Mirror {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := make([]*Mirror, len(g.mirrors))
+	copy(ms, g.mirrors)
+	sort.SliceStable(ms, func(i, j int) bool {
+		return ms[i].score() < ms[j].score()
+	})
+	if len(ms) < 2 {
+		return ms
+	}
+
+	var total float64
+	weights := make([]float64, len(ms))
+	for i, m := range ms {
+		weights[i] = 1 / (m.score() + 1)
+		total += weights[i]
+	}
+	pick := rand.Float64() * total
+	for i, w := range weights {
+		if pick < w {
+			first := ms[i]
+			copy(ms[1:i+1], ms[:i])
+			ms[0] = first
+			break
+		}
+		pick -= w
+	}
+	return ms
+}
+
+// Report 反馈一次请求的结果，latency为拿到响应头的耗时
+func (g *Group) Report(m *Mirror, latency time.Duration, err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if err != nil {
+		m.failures++
+		return
+	}
+	m.failures = 0
+	m.latency = time.Duration((1-ewmaWeight)*float64(m.latency) + ewmaWeight*float64(latency))
+}
+
+// Hosts 返回组内所有镜像的host
+func (g *Group) Hosts() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var hosts []string
+	for _, m := range g.mirrors {
+		if u, err := url.Parse(m.Base); err == nil {
+			hosts = append(hosts, u.Host)
+		}
+	}
+	return hosts
+}
+
+// Bases 按配置顺序返回组内所有镜像的地址
+func (g *Group) Bases() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var bases []string
+	for _, m := range g.mirrors {
+		bases = append(bases, m.Base)
+	}
+	return bases
+}
+
+// URL 拼接镜像地址与相对路径
+func (m *Mirror) URL(p string) string {
+	return m.Base + strings.TrimPrefix(p, "/")
+}
@@ -1,15 +1,51 @@
 package recorder
 
 import (
-	"github.com/syndtr/goleveldb/leveldb"
+	"encoding/json"
+	"get_package_md5/model"
 	"log"
 	"os"
 	"sync"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
 )
 
+// 失败记录的分类
+const (
+	ClassNetwork  = "network"
+	ClassStatus   = "status"
+	ClassParse    = "parse"
+	ClassChecksum = "checksum"
+	// ClassUnsupported 包的格式暂不支持，需要更新解析器后再重试
+	ClassUnsupported = "unsupported"
+	ClassUnknown     = "unknown"
+)
+
+// failurePrefix 失败记录在db中的key前缀，已完成的记录直接以路径为key
+const failurePrefix = "failed:"
+
+// Failure 一次下载失败的记录，重复失败时累加Attempts
+type Failure struct {
+	Key  string `json:"key"`
+	URL  string `json:"url,omitempty"`
+	Repo string `json:"repo,omitempty"`
+	Path string `json:"path,omitempty"`
+	// Mirrors 失败时Repo对应的镜像，retry时未注册该源也可以据此重新下载
+	Mirrors  []string         `json:"mirrors,omitempty"`
+	File     string           `json:"file,omitempty"`
+	Meta     *model.IndexMeta `json:"meta,omitempty"`
+	Attempts int              `json:"attempts"`
+	Class    string           `json:"class"`
+	Error    string           `json:"error"`
+	Time     time.Time        `json:"time"`
+}
+
 type AccessRecorder struct {
 	recordChannel   chan string
 	errorRecordChan chan string
+	failureChan     chan *Failure
 	wg              sync.WaitGroup
 	errorFile       *os.File
 	db              *leveldb.DB
@@ -29,13 +65,15 @@ func NewAccessRecorder(cache string) (*AccessRecorder, error) {
 	logger := &AccessRecorder{
 		recordChannel:   make(chan string, 100),
 		errorRecordChan: make(chan string, 100),
+		failureChan:     make(chan *Failure, 100),
 		errorFile:       errorFile,
 		db:              db,
 	}
 
-	logger.wg.Add(2)
+	logger.wg.Add(3)
 	go logger.processRecords()
 	go logger.processErrorRecords()
+	go logger.processFailures()
 
 	return logger, nil
 }
@@ -60,12 +98,66 @@ func (l *AccessRecorder) RecordError(errorMessage string) {
 	l.errorRecordChan <- errorMessage
 }
 
+// RecordFailure 记录一次下载失败，用于之后的重试，Attempts由recorder累加
+func (l *AccessRecorder) RecordFailure(f *Failure) {
+	f.Time = time.Now()
+	l.failureChan <- f
+}
+
+// Failures 遍历所有失败记录
+func (l *AccessRecorder) Failures(do func(f *Failure) error) error {
+	iter := l.db.NewIterator(util.BytesPrefix([]byte(failurePrefix)), nil)
+	defer iter.Release()
+
+	for iter.Next() {
+		f := new(Failure)
+		if err := json.Unmarshal(iter.Value(), f); err != nil {
+			log.Println("Error decoding failure record:", err)
+			continue
+		}
+		if err := do(f); err != nil {
+			return err
+		}
+	}
+	return iter.Error()
+}
+
 func (l *AccessRecorder) processRecords() {
 	defer l.wg.Done()
 	for record := range l.recordChannel {
 		if err := l.db.Put([]byte(record), []byte{0}, nil); err != nil {
 			log.Println("Error writing to db:", err)
 		}
+		// 重试成功后移除失败记录
+		if err := l.db.Delete([]byte(failurePrefix+record), nil); err != nil {
+			log.Println("Error deleting from db:", err)
+		}
+	}
+}
+
+func (l *AccessRecorder) processFailures() {
+	defer l.wg.Done()
+	for f := range l.failureChan {
+		// 两个channel分别处理，失败记录可能晚于成功记录到达
+		if l.Exist(f.Key) {
+			continue
+		}
+		key := []byte(failurePrefix + f.Key)
+		if data, err := l.db.Get(key, nil); err == nil {
+			old := new(Failure)
+			if json.Unmarshal(data, old) == nil {
+				f.Attempts = old.Attempts
+			}
+		}
+		f.Attempts++
+		data, err := json.Marshal(f)
+		if err != nil {
+			log.Println("Error encoding failure record:", err)
+			continue
+		}
+		if err := l.db.Put(key, data, nil); err != nil {
+			log.Println("Error writing to db:", err)
+		}
 	}
 }
 
@@ -81,6 +173,7 @@ func (l *AccessRecorder) processErrorRecords() {
 func (l *AccessRecorder) Close() error {
 	close(l.recordChannel)
 	close(l.errorRecordChan)
+	close(l.failureChan)
 	l.wg.Wait() // 等待所有记录都被处理完
 	if err := l.db.Close(); err != nil {
 		return err
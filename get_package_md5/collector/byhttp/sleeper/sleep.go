@@ -1,6 +1,7 @@
 package sleeper
 
 import (
+	"context"
 	"math/rand"
 	"time"
 )
@@ -24,3 +25,27 @@ func (s *Sleeper) Sleep() {
 	delay := s.baseDelay + time.Duration(rand.Int63n(int64(s.jitter)))
 	time.Sleep(delay)
 }
+
+// MaxBackoff 指数退避的最长等待时间(不含随机浮动)，也是Retry-After暂停host的上限
+const MaxBackoff = 5 * time.Minute
+
+// Backoff 第attempt次重试前的指数退避睡眠，attempt从0开始，ctx取消时提前返回
+func (s *Sleeper) Backoff(ctx context.Context, attempt int) {
+	delay := s.baseDelay
+	for i := 0; i < attempt && delay < MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > MaxBackoff {
+		delay = MaxBackoff
+	}
+	if s.jitter > 0 {
+		delay += time.Duration(rand.Int63n(int64(s.jitter)))
+	}
+
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+	}
+}
@@ -124,6 +124,8 @@ func (l *sender) send() {
 		for _, hash := range v.Hashes {
 			doc.Hashes = append(doc.Hashes, model.Hash{Key: hash.Key, Value: hash.Value})
 		}
+		doc.Relations = v.Relations
+		doc.Files = v.Files
 		doc.License = v.License
 		doc.Maintainer = v.Maintainer
 		doc.Homepage = v.URL
@@ -126,6 +126,8 @@ func (l *sender) send() {
 		for k, v := range v.Hashes {
 			doc.Hashes = append(doc.Hashes, model.Hash{Key: k, Value: v})
 		}
+		doc.Relations = v.Relations
+		doc.Files = v.Files
 		for _, l := range v.Licences {
 			doc.License = append(doc.License, l.Names...)
 		}
@@ -0,0 +1,284 @@
+package image
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"get_package_md5/model"
+	"get_package_md5/unarchiver"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+)
+
+// 索引与manifest的媒体类型，index可以嵌套
+const (
+	mediaTypeOCIIndex    = "application/vnd.oci.image.index.v1+json"
+	mediaTypeDockerList  = "application/vnd.docker.distribution.manifest.list.v2+json"
+	annotationRefName    = "org.opencontainers.image.ref.name"
+	annotationContainerd = "io.containerd.image.name"
+	// annotationRefType buildx生成的attestation manifest带有该注解，其中的层不是文件系统
+	annotationRefType = "vnd.docker.reference.type"
+)
+
+// maxIndexDepth 嵌套index的最大层数
+const maxIndexDepth = 4
+
+// Image 镜像的来源、平台以及按从下到上顺序排列的层
+type Image struct {
+	Source       model.ImageSource
+	OS           string
+	Architecture string
+	Layers       []*model.ImageLayer
+	// blobs 每一层在store中的路径，与Layers一一对应
+	blobs []string
+	store store
+}
+
+type descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+type index struct {
+	Manifests []descriptor `json:"manifests"`
+}
+
+type manifest struct {
+	Config descriptor   `json:"config"`
+	Layers []descriptor `json:"layers"`
+}
+
+// dockerManifest docker save生成的manifest.json中的一项
+type dockerManifest struct {
+	Config   string   `json:"Config"`
+	RepoTags []string `json:"RepoTags"`
+	Layers   []string `json:"Layers"`
+}
+
+type imageConfig struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	RootFS       struct {
+		DiffIDs []string `json:"diff_ids"`
+	} `json:"rootfs"`
+	History []struct {
+		CreatedBy  string `json:"created_by"`
+		EmptyLayer bool   `json:"empty_layer"`
+	} `json:"history"`
+}
+
+// Load 读取p中的所有镜像，p为OCI layout目录、docker save生成的tar或其解压后的目录
+// 返回的Closer在镜像使用完后关闭
+func Load(p string) ([]*Image, io.Closer, error) {
+	info, err := os.Stat(p)
+	if err != nil {
+		return nil, nil, err
+	}
+	var s store
+	if info.IsDir() {
+		s = &dirStore{dir: p}
+	} else {
+		if s, err = openTarStore(p); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var images []*Image
+	switch {
+	case s.exists("index.json"):
+		images, err = loadOCI(s)
+	case s.exists("manifest.json"):
+		images, err = loadDocker(s)
+	default:
+		err = errors.Errorf("%s is neither an oci layout nor a docker save archive", p)
+	}
+	if err != nil {
+		s.Close()
+		return nil, nil, err
+	}
+	return images, s, nil
+}
+
+// loadOCI 从index.json开始读取manifest，新版docker save生成的tar同样是OCI layout
+func loadOCI(s store) ([]*Image, error) {
+	var idx index
+	if err := readJSON(s, "index.json", &idx); err != nil {
+		return nil, err
+	}
+	var images []*Image
+	if err := walkIndex(s, idx, "", 0, func(desc descriptor, ref string) error {
+		var m manifest
+		if err := readJSON(s, blobPath(desc.Digest), &m); err != nil {
+			return err
+		}
+		img := &Image{store: s}
+		img.Source.Ref = ref
+		img.Source.Digest = desc.Digest
+		for _, l := range m.Layers {
+			img.blobs = append(img.blobs, blobPath(l.Digest))
+			img.Layers = append(img.Layers, &model.ImageLayer{Digest: l.Digest, Size: l.Size})
+		}
+		if err := img.loadConfig(blobPath(m.Config.Digest)); err != nil {
+			return err
+		}
+		images = append(images, img)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
+// walkIndex 遍历index中的manifest，多平台镜像的index中嵌套了每个平台的manifest
+func walkIndex(s store, idx index, ref string, depth int, do func(desc descriptor, ref string) error) error {
+	for _, desc := range idx.Manifests {
+		r := ref
+		if name := desc.Annotations[annotationRefName]; name != "" {
+			r = name
+		} else if name := desc.Annotations[annotationContainerd]; name != "" {
+			r = name
+		}
+		if desc.Annotations[annotationRefType] != "" {
+			continue
+		}
+		if desc.MediaType != mediaTypeOCIIndex && desc.MediaType != mediaTypeDockerList {
+			if err := do(desc, r); err != nil {
+				return err
+			}
+			continue
+		}
+		if depth >= maxIndexDepth {
+			return errors.Errorf("index nested too deep at %s", desc.Digest)
+		}
+		var child index
+		if err := readJSON(s, blobPath(desc.Digest), &child); err != nil {
+			return err
+		}
+		if err := walkIndex(s, child, r, depth+1, do); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadDocker 读取旧版docker save的manifest.json，层为<id>/layer.tar，没有manifest的摘要
+func loadDocker(s store) ([]*Image, error) {
+	var manifests []dockerManifest
+	if err := readJSON(s, "manifest.json", &manifests); err != nil {
+		return nil, err
+	}
+	var images []*Image
+	for _, m := range manifests {
+		img := &Image{store: s}
+		if len(m.RepoTags) > 0 {
+			img.Source.Ref = m.RepoTags[0]
+		}
+		for _, l := range m.Layers {
+			layer := &model.ImageLayer{}
+			// 新版docker的层位于blobs/sha256/<hex>
+			if dir, sum := path.Split(l); strings.HasPrefix(dir, "blobs/") {
+				layer.Digest = path.Base(dir) + ":" + sum
+			}
+			img.blobs = append(img.blobs, l)
+			img.Layers = append(img.Layers, layer)
+		}
+		if err := img.loadConfig(m.Config); err != nil {
+			return nil, err
+		}
+		images = append(images, img)
+	}
+	return images, nil
+}
+
+// loadConfig 读取镜像config，ID为其sha256，每层的diff_id与history对应
+func (img *Image) loadConfig(name string) error {
+	r, err := img.store.open(name)
+	if err != nil {
+		return errors.WithMessagef(err, "open config %s", name)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return errors.WithMessagef(err, "read config %s", name)
+	}
+	sum := sha256.Sum256(data)
+	img.Source.ID = "sha256:" + hex.EncodeToString(sum[:])
+
+	var c imageConfig
+	if err := jsoniter.Unmarshal(data, &c); err != nil {
+		return errors.WithMessagef(err, "decode config %s", name)
+	}
+	img.OS = c.OS
+	img.Architecture = c.Architecture
+
+	// empty_layer的history没有对应的层
+	var createdBy []string
+	for _, h := range c.History {
+		if !h.EmptyLayer {
+			createdBy = append(createdBy, h.CreatedBy)
+		}
+	}
+	for i, l := range img.Layers {
+		l.Index = i
+		if i < len(c.RootFS.DiffIDs) {
+			l.DiffID = c.RootFS.DiffIDs[i]
+		}
+		if i < len(createdBy) {
+			l.CreatedBy = createdBy[i]
+		}
+	}
+	return nil
+}
+
+// Walk 按从下到上的顺序遍历每一层中的文件，层的压缩方式由文件头判断
+func (img *Image) Walk(ctx context.Context, do func(layer int, n string, r io.Reader) error) error {
+	for i, blob := range img.blobs {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := img.walkLayer(ctx, i, blob, do); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (img *Image) walkLayer(ctx context.Context, i int, blob string, do func(layer int, n string, r io.Reader) error) error {
+	r, err := img.store.open(blob)
+	if err != nil {
+		return errors.WithMessagef(err, "open layer %s", blob)
+	}
+	defer r.Close()
+
+	if err := unarchiver.ReadTarAuto(unarchiver.WithContext(ctx, r), func(n string, r io.Reader) error {
+		return do(i, n, r)
+	}); err != nil {
+		return errors.WithMessagef(err, "read layer %s", blob)
+	}
+	return nil
+}
+
+func readJSON(s store, name string, v interface{}) error {
+	r, err := s.open(name)
+	if err != nil {
+		return errors.WithMessagef(err, "open %s", name)
+	}
+	defer r.Close()
+	if err := jsoniter.NewDecoder(r).Decode(v); err != nil {
+		return errors.WithMessagef(err, "decode %s", name)
+	}
+	return nil
+}
+
+// blobPath OCI layout中摘要对应的文件，sha256:<hex>位于blobs/sha256/<hex>
+func blobPath(digest string) string {
+	alg, sum, _ := strings.Cut(digest, ":")
+	return path.Join("blobs", alg, sum)
+}
@@ -0,0 +1,117 @@
+package image
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// maxLinkDepth 解析tar中符号链接的最大层数
+const maxLinkDepth = 8
+
+// store 按相对路径读取镜像中的文件(index.json、manifest.json、blob等)
+type store interface {
+	open(name string) (io.ReadCloser, error)
+	exists(name string) bool
+	Close() error
+}
+
+// dirStore OCI layout目录或解压后的docker save目录
+type dirStore struct {
+	dir string
+}
+
+func (s *dirStore) open(name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(s.dir, filepath.FromSlash(path.Clean("/"+name))))
+}
+
+func (s *dirStore) exists(name string) bool {
+	_, err := os.Stat(filepath.Join(s.dir, filepath.FromSlash(path.Clean("/"+name))))
+	return err == nil
+}
+
+func (s *dirStore) Close() error {
+	return nil
+}
+
+// tarStore docker save生成的tar，打开时记录每个文件在tar中的偏移，之后按偏移随机读取，不需要解包
+type tarStore struct {
+	f       *os.File
+	entries map[string]tarEntry
+}
+
+type tarEntry struct {
+	offset int64
+	size   int64
+	// link 符号链接的目标，旧版docker save中相同的层以符号链接指向第一次出现的层
+	link string
+}
+
+func openTarStore(p string) (*tarStore, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	s := &tarStore{f: f, entries: map[string]tarEntry{}}
+	tr := tar.NewReader(f)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			f.Close()
+			return nil, errors.WithMessagef(err, "read tar %s", p)
+		}
+		name := cleanName(h.Name)
+		switch h.Typeflag {
+		case tar.TypeReg:
+			// archive/tar不缓冲，读完头部后文件的当前位置即为内容的起始位置
+			offset, err := f.Seek(0, io.SeekCurrent)
+			if err != nil {
+				f.Close()
+				return nil, err
+			}
+			s.entries[name] = tarEntry{offset: offset, size: h.Size}
+		case tar.TypeSymlink:
+			s.entries[name] = tarEntry{link: cleanName(path.Join(path.Dir(name), h.Linkname))}
+		case tar.TypeLink:
+			s.entries[name] = tarEntry{link: cleanName(h.Linkname)}
+		}
+	}
+	return s, nil
+}
+
+func (s *tarStore) lookup(name string) (tarEntry, bool) {
+	e, ok := s.entries[cleanName(name)]
+	for i := 0; ok && e.link != "" && i < maxLinkDepth; i++ {
+		e, ok = s.entries[e.link]
+	}
+	return e, ok && e.link == ""
+}
+
+func (s *tarStore) open(name string) (io.ReadCloser, error) {
+	e, ok := s.lookup(name)
+	if !ok {
+		return nil, errors.Wrapf(os.ErrNotExist, "open %s", name)
+	}
+	return io.NopCloser(io.NewSectionReader(s.f, e.offset, e.size)), nil
+}
+
+func (s *tarStore) exists(name string) bool {
+	_, ok := s.lookup(name)
+	return ok
+}
+
+func (s *tarStore) Close() error {
+	return s.f.Close()
+}
+
+func cleanName(n string) string {
+	return strings.TrimPrefix(path.Clean("/"+n), "/")
+}
@@ -0,0 +1,22 @@
+package model
+
+// Copyright 机器可读(DEP-5)格式的debian/copyright，License均为规范化后的SPDX表达式
+// Upstream为Upstream-Name，Source为上游源码的地址
+type Copyright struct {
+	Path      string            `json:"path"`
+	Format    string            `json:"format"`
+	Upstream  string            `json:"upstream,omitempty"`
+	Source    string            `json:"source,omitempty"`
+	Copyright string            `json:"copyright,omitempty"`
+	License   string            `json:"license,omitempty"`
+	Files     []*CopyrightFiles `json:"files,omitempty"`
+}
+
+// CopyrightFiles copyright中的一个Files段，Patterns相对于源码根目录，后出现的段优先
+// Raw为文件中原始的许可证简称
+type CopyrightFiles struct {
+	Patterns  []string `json:"patterns"`
+	Copyright string   `json:"copyright,omitempty"`
+	License   string   `json:"license"`
+	Raw       string   `json:"raw,omitempty"`
+}
@@ -0,0 +1,30 @@
+package model
+
+// ImageSource 包来自容器镜像时的来源，Digest为manifest的摘要(docker save的旧格式没有)，ID为config的摘要即镜像ID
+type ImageSource struct {
+	Ref    string `json:"ref,omitempty"`
+	Digest string `json:"digest,omitempty"`
+	ID     string `json:"id"`
+}
+
+// ImageLayer 镜像中的一层，Digest为层blob的摘要(可能是压缩后的)，DiffID为解压后的摘要
+// CreatedBy为config的history中生成该层的指令
+type ImageLayer struct {
+	Index     int    `json:"index"`
+	Digest    string `json:"digest,omitempty"`
+	DiffID    string `json:"diff_id,omitempty"`
+	Size      int64  `json:"size,omitempty"`
+	CreatedBy string `json:"created_by,omitempty"`
+}
+
+// ImageDoc 一个镜像的汇总，Packages为识别出的已安装包(管理器:名称=版本)
+// Unowned为不属于任何已安装包的ELF文件，Unsupported为发现但无法解析的包数据库
+type ImageDoc struct {
+	ImageSource
+	OS           string        `json:"os,omitempty"`
+	Architecture string        `json:"architecture,omitempty"`
+	Layers       []*ImageLayer `json:"layers"`
+	Packages     []string      `json:"packages,omitempty"`
+	Unowned      []*File       `json:"unowned,omitempty"`
+	Unsupported  []string      `json:"unsupported,omitempty"`
+}
@@ -0,0 +1,29 @@
+package model
+
+// IndexMeta 仓库索引中记录的包元数据，由discovery在下载前提供
+type IndexMeta struct {
+	OS           string    `json:"os,omitempty"`
+	Suite        string    `json:"suite,omitempty"`
+	Repository   string    `json:"repository,omitempty"`
+	Architecture string    `json:"architecture,omitempty"`
+	Name         string    `json:"name,omitempty"`
+	Version      string    `json:"version,omitempty"`
+	Origin       string    `json:"origin,omitempty"`
+	Size         int64     `json:"size,omitempty"`
+	Checksum     *Checksum `json:"checksum,omitempty"`
+	// Path 包在镜像站上的路径
+	Path string `json:"path,omitempty"`
+	// Digest 下载内容的sha256，Verified表示已与索引中的校验值比对通过
+	// Checks 实际比对通过的项，如size、sha256、apk-q1，索引中只有大小时只有size
+	Digest   *Checksum `json:"digest,omitempty"`
+	Verified bool      `json:"verified,omitempty"`
+	Checks   []string  `json:"checks,omitempty"`
+	// Image 从容器镜像中已安装的包数据库识别出的包，记录镜像来源
+	Image *ImageSource `json:"image,omitempty"`
+}
+
+// Checksum 索引中给出的校验值，Type为算法名(sha256、sha1、md5、apk-q1等)
+type Checksum struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
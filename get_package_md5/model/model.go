@@ -1,23 +1,76 @@
 package model
 
 type Document struct {
-	Os           string   `json:"os"`
-	Epoch        int      `json:"epoch"`
-	Release      string   `json:"release"`
-	Manager      string   `json:"manager"`
-	Name         string   `json:"name"`
-	Source       string   `json:"source"`
-	Version      string   `json:"version"`
-	Architecture string   `json:"architecture"`
-	Maintainer   string   `json:"maintainer"`
-	Homepage     string   `json:"homepage"`
-	Description  string   `json:"description"`
-	License      []string `json:"license"`
-	Depends      []string `json:"depends"`
-	Hashes       []Hash   `json:"hashes"`
+	Os           string      `json:"os"`
+	Epoch        int         `json:"epoch"`
+	Release      string      `json:"release"`
+	Manager      string      `json:"manager"`
+	Name         string      `json:"name"`
+	Source       string      `json:"source"`
+	Version      string      `json:"version"`
+	Architecture string      `json:"architecture"`
+	Maintainer   string      `json:"maintainer"`
+	Homepage     string      `json:"homepage"`
+	Description  string      `json:"description"`
+	License      []string    `json:"license"`
+	Depends      []string    `json:"depends"`
+	Relations    []*Relation `json:"relations,omitempty"`
+	Hashes       []Hash      `json:"hashes"`
+	Files        []*File     `json:"files,omitempty"`
 }
 
+// Hash md5到文件路径的映射，保留用于兼容已入库的数据，新数据以Files为准
 type Hash struct {
 	Key   string `json:"key"`
 	Value string `json:"value"`
 }
+
+// File 包内的ELF文件，Mode为八进制表示的权限位
+// TLSH为局部敏感哈希，TLSHBands为其切分后的片段，用于在es中检索相似文件
+// License为根据DEP-5 copyright归属到该文件的SPDX表达式，Layer为来自容器镜像时所在层的diff_id
+type File struct {
+	Path      string     `json:"path"`
+	Size      int64      `json:"size"`
+	Mode      string     `json:"mode,omitempty"`
+	MD5       string     `json:"md5"`
+	SHA1      string     `json:"sha1"`
+	SHA256    string     `json:"sha256"`
+	TLSH      string     `json:"tlsh,omitempty"`
+	TLSHBands []string   `json:"tlsh_bands,omitempty"`
+	Elf       *ElfInfo   `json:"elf,omitempty"`
+	Build     *BuildInfo `json:"build,omitempty"`
+	License   string     `json:"license,omitempty"`
+	Layer     string     `json:"layer,omitempty"`
+}
+
+// ElfInfo ELF文件的元数据，build-id在strip之后依然保留，可作为查询的依据
+// Machine形如x86_64、aarch64，Class为32或64，Type为exec、dyn、rel或core
+type ElfInfo struct {
+	BuildID     string   `json:"build_id,omitempty"`
+	Soname      string   `json:"soname,omitempty"`
+	Needed      []string `json:"needed,omitempty"`
+	Machine     string   `json:"machine,omitempty"`
+	Class       string   `json:"class,omitempty"`
+	Endian      string   `json:"endian,omitempty"`
+	Interpreter string   `json:"interpreter,omitempty"`
+	Stripped    bool     `json:"stripped"`
+	Type        string   `json:"type,omitempty"`
+}
+
+// BuildInfo 二进制中嵌入的构建信息，Lang为go或rust，Toolchain为go的版本
+// Deps为编译进二进制的依赖模块(rust为crate)
+type BuildInfo struct {
+	Lang      string    `json:"lang"`
+	Toolchain string    `json:"toolchain,omitempty"`
+	Main      *Module   `json:"main,omitempty"`
+	Deps      []*Module `json:"deps,omitempty"`
+}
+
+// Module go模块或rust crate，Source为crate的来源(如crates.io)
+type Module struct {
+	Path    string  `json:"path"`
+	Version string  `json:"version,omitempty"`
+	Sum     string  `json:"sum,omitempty"`
+	Source  string  `json:"source,omitempty"`
+	Replace *Module `json:"replace,omitempty"`
+}
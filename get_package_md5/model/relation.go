@@ -0,0 +1,21 @@
+package model
+
+// Relation 包之间的一条关系，Kind为depends、pre-depends、recommends、suggests、enhances、
+// provides、conflicts、breaks、replaces、obsoletes、built-using等
+// Alternatives为"a | b"形式的可选项，满足其一即可，没有可选项时只有一个元素
+type Relation struct {
+	Kind         string        `json:"kind"`
+	Alternatives []*Dependency `json:"alternatives"`
+}
+
+// Dependency 关系中的一个包或能力
+// Namespace区分名称的类型：so为共享库(apk的so:、rpm的libfoo.so.1()(64bit))，
+// file为文件路径，其余如cmd、pc、pkgconfig、perl等保留原始前缀，普通包名为空
+// Operator统一为<、<=、=、>=、>、~，Arch为deb的架构限定(如any)或rpm的64bit标记
+type Dependency struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	Operator  string `json:"operator,omitempty"`
+	Version   string `json:"version,omitempty"`
+	Arch      string `json:"arch,omitempty"`
+}
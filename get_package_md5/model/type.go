@@ -1,60 +1,154 @@
 package model
 
 type RpmPkg struct {
-	Epoch              int      `json:"epoch,omitempty"`
-	OS                 string   `json:"os,omitempty"`
-	Vendor             string   `json:"vendor,omitempty"`
-	Release            string   `json:"release,omitempty"`
-	Manager            string   `json:"manager,omitempty"`
-	Name               string   `json:"name,omitempty"`
-	Source             string   `json:"source,omitempty"`
-	Version            string   `json:"version,omitempty"`
-	Architecture       string   `json:"architecture,omitempty"`
-	Maintainer         string   `json:"maintainer,omitempty"`
-	OriginalMaintainer string   `json:"originalMaintainer,omitempty"`
-	Homepage           string   `json:"homepage,omitempty"`
-	Description        string   `json:"description,omitempty"`
-	Depends            []string `json:"depends,omitempty"`
-	License            []string `json:"license,omitempty"`
-	Hashes             []Hash   `json:"hashes,omitempty"`
+	Epoch              int         `json:"epoch,omitempty"`
+	OS                 string      `json:"os,omitempty"`
+	Vendor             string      `json:"vendor,omitempty"`
+	Release            string      `json:"release,omitempty"`
+	Manager            string      `json:"manager,omitempty"`
+	Name               string      `json:"name,omitempty"`
+	Source             string      `json:"source,omitempty"`
+	Version            string      `json:"version,omitempty"`
+	Architecture       string      `json:"architecture,omitempty"`
+	Maintainer         string      `json:"maintainer,omitempty"`
+	OriginalMaintainer string      `json:"originalMaintainer,omitempty"`
+	Homepage           string      `json:"homepage,omitempty"`
+	Description        string      `json:"description,omitempty"`
+	Depends            []string    `json:"depends,omitempty"`
+	Relations          []*Relation `json:"relations,omitempty"`
+	License            []string    `json:"license,omitempty"`
+	Licences           []*License  `json:"licence,omitempty"`
+	Hashes             []Hash      `json:"hashes,omitempty"`
+	Files              []*File     `json:"files,omitempty"`
+	// DigestAlgo 头部中文件摘要使用的算法，Manifest为头部记录的全部文件
+	DigestAlgo string     `json:"digestAlgo,omitempty"`
+	Manifest   []*RpmFile `json:"manifest,omitempty"`
+	// Mismatches 计算出的ELF摘要或大小与头部记录不一致的文件
+	Mismatches []*DigestMismatch `json:"mismatches,omitempty"`
+	Index      *IndexMeta        `json:"index,omitempty"`
+}
+
+// RpmFile rpm头部中记录的文件，Flags为config、doc、license等标记
+type RpmFile struct {
+	Path     string   `json:"path"`
+	Size     int64    `json:"size"`
+	Mode     string   `json:"mode"`
+	Digest   string   `json:"digest,omitempty"`
+	Owner    string   `json:"owner,omitempty"`
+	Group    string   `json:"group,omitempty"`
+	Linkname string   `json:"linkname,omitempty"`
+	Flags    []string `json:"flags,omitempty"`
+}
+
+// HasFlag 文件是否带有标记flag，f为nil时返回false
+func (f *RpmFile) HasFlag(flag string) bool {
+	if f == nil {
+		return false
+	}
+	for _, fl := range f.Flags {
+		if fl == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// DigestMismatch 包内文件与包的元数据不一致，Reason为不一致的项(size或摘要算法)
+type DigestMismatch struct {
+	Path     string `json:"path"`
+	Reason   string `json:"reason"`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
 }
 
 type DebPkg struct {
-	OS                 string            `json:"os,omitempty"`
-	OriginName         string            `json:"originName,omitempty"`
-	Name               string            `json:"name,omitempty"`
-	Source             string            `json:"source,omitempty"`
-	Version            string            `json:"version,omitempty"`
-	Architecture       string            `json:"architecture,omitempty"`
-	Maintainer         string            `json:"maintainer,omitempty"`
-	OriginalMaintainer string            `json:"originalMaintainer,omitempty"`
-	Section            string            `json:"section,omitempty"`
-	Priority           string            `json:"priority,omitempty"`
-	Homepage           string            `json:"homepage,omitempty"`
-	Description        string            `json:"description,omitempty"`
-	InstalledSize      string            `json:"installedSize,omitempty"`
-	Suggests           []string          `json:"suggests,omitempty"`
-	Depends            []string          `json:"depends,omitempty"`
-	Licences           []*License        `json:"licence,omitempty"`
-	Hashes             map[string]string `json:"hashes,omitempty"`
+	OS                 string      `json:"os,omitempty"`
+	OriginName         string      `json:"originName,omitempty"`
+	Name               string      `json:"name,omitempty"`
+	Source             string      `json:"source,omitempty"`
+	Version            string      `json:"version,omitempty"`
+	Architecture       string      `json:"architecture,omitempty"`
+	Maintainer         string      `json:"maintainer,omitempty"`
+	OriginalMaintainer string      `json:"originalMaintainer,omitempty"`
+	Section            string      `json:"section,omitempty"`
+	Priority           string      `json:"priority,omitempty"`
+	Homepage           string      `json:"homepage,omitempty"`
+	Description        string      `json:"description,omitempty"`
+	InstalledSize      string      `json:"installedSize,omitempty"`
+	Suggests           []string    `json:"suggests,omitempty"`
+	Depends            []string    `json:"depends,omitempty"`
+	Relations          []*Relation `json:"relations,omitempty"`
+	PreDepends         []string    `json:"preDepends,omitempty"`
+	Recommends         []string    `json:"recommends,omitempty"`
+	Enhances           []string    `json:"enhances,omitempty"`
+	Provides           []string    `json:"provides,omitempty"`
+	Conflicts          []string    `json:"conflicts,omitempty"`
+	Breaks             []string    `json:"breaks,omitempty"`
+	Replaces           []string    `json:"replaces,omitempty"`
+	BuiltUsing         []string    `json:"builtUsing,omitempty"`
+	MultiArch          string      `json:"multiArch,omitempty"`
+	Essential          bool        `json:"essential,omitempty"`
+	// Extra control中未单独映射的字段
+	Extra    map[string]string `json:"extra,omitempty"`
+	Licences []*License        `json:"licence,omitempty"`
+	// Copyright 声明了Format的copyright文件的解析结果
+	Copyright *Copyright        `json:"copyright,omitempty"`
+	Hashes    map[string]string `json:"hashes,omitempty"`
+	Files     []*File           `json:"files,omitempty"`
+	// Manifest md5sums中记录的文件清单，Conffiles为配置文件的绝对路径
+	Manifest  []*DebFile `json:"manifest,omitempty"`
+	Conffiles []string   `json:"conffiles,omitempty"`
+	// Shlibs 包提供的共享库，Triggers为triggers文件中的声明
+	Shlibs   []*Shlib  `json:"shlibs,omitempty"`
+	Triggers []string  `json:"triggers,omitempty"`
+	Scripts  []*Script `json:"scripts,omitempty"`
+	// Mismatches 计算出的ELF摘要与md5sums不一致的文件
+	Mismatches []*DigestMismatch `json:"mismatches,omitempty"`
+	Index      *IndexMeta        `json:"index,omitempty"`
+}
+
+// DebFile md5sums中的一条记录，Path为相对根目录的路径
+type DebFile struct {
+	Path string `json:"path"`
+	MD5  string `json:"md5"`
+}
+
+// Shlib shlibs中的一条记录，Type为可选的包类型(如udeb)
+type Shlib struct {
+	Type    string `json:"type,omitempty"`
+	Library string `json:"library"`
+	Version string `json:"version"`
+	Depends string `json:"depends,omitempty"`
+}
+
+// Script 维护脚本(preinst、postinst等)的摘要
+type Script struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	MD5    string `json:"md5"`
+	SHA256 string `json:"sha256"`
 }
 
 type ApkPkg struct {
-	OS         string   `json:"OS,omitempty"`
-	PkgName    string   `json:"pkgname,omitempty"`
-	PkgVer     string   `json:"pkgver,omitempty"`
-	PkgDesc    string   `json:"pkgdesc,omitempty"`
-	URL        string   `json:"url,omitempty"`
-	BuildDate  string   `json:"builddate,omitempty"`
-	Packager   string   `json:"packager,omitempty"`
-	Size       string   `json:"size,omitempty"`
-	Arch       string   `json:"arch,omitempty"`
-	Origin     string   `json:"origin,omitempty"`
-	Maintainer string   `json:"maintainer,omitempty"`
-	Replaces   string   `json:"replaces,omitempty"`
-	Depend     []string `json:"depend,omitempty"`
-	License    []string `json:"license,omitempty"`
-	Hashes     []Hash   `json:"hashes,omitempty"`
+	OS         string      `json:"OS,omitempty"`
+	PkgName    string      `json:"pkgname,omitempty"`
+	PkgVer     string      `json:"pkgver,omitempty"`
+	PkgDesc    string      `json:"pkgdesc,omitempty"`
+	URL        string      `json:"url,omitempty"`
+	BuildDate  string      `json:"builddate,omitempty"`
+	Packager   string      `json:"packager,omitempty"`
+	Size       string      `json:"size,omitempty"`
+	Arch       string      `json:"arch,omitempty"`
+	Origin     string      `json:"origin,omitempty"`
+	Maintainer string      `json:"maintainer,omitempty"`
+	Replaces   string      `json:"replaces,omitempty"`
+	Depend     []string    `json:"depend,omitempty"`
+	Provides   []string    `json:"provides,omitempty"`
+	Relations  []*Relation `json:"relations,omitempty"`
+	License    []string    `json:"license,omitempty"`
+	Hashes     []Hash      `json:"hashes,omitempty"`
+	Files      []*File     `json:"files,omitempty"`
+	Index      *IndexMeta  `json:"index,omitempty"`
 }
 
 type License struct {
@@ -64,18 +158,20 @@ type License struct {
 }
 
 type CommonPkg struct {
-	Manager            string   `json:"manager"`
-	Name               string   `json:"name"`
-	Source             string   `json:"source"`
-	Version            string   `json:"version"`
-	Architecture       string   `json:"architecture"`
-	Maintainer         string   `json:"maintainer"`
-	OriginalMaintainer string   `json:"originalMaintainer"`
-	Homepage           string   `json:"homepage"`
-	Description        string   `json:"description"`
-	Depends            []string `json:"depends"`
-	License            []string `json:"license"`
-	Hashes             []Hash   `json:"hashes"`
+	Manager            string      `json:"manager"`
+	Name               string      `json:"name"`
+	Source             string      `json:"source"`
+	Version            string      `json:"version"`
+	Architecture       string      `json:"architecture"`
+	Maintainer         string      `json:"maintainer"`
+	OriginalMaintainer string      `json:"originalMaintainer"`
+	Homepage           string      `json:"homepage"`
+	Description        string      `json:"description"`
+	Depends            []string    `json:"depends"`
+	Relations          []*Relation `json:"relations,omitempty"`
+	License            []string    `json:"license"`
+	Hashes             []Hash      `json:"hashes"`
+	Files              []*File     `json:"files,omitempty"`
 }
 
 func Convert(pkg *DebPkg, mt string) *CommonPkg {
@@ -92,6 +188,7 @@ func Convert(pkg *DebPkg, mt string) *CommonPkg {
 	cp.Maintainer = pkg.Maintainer
 	cp.OriginalMaintainer = pkg.OriginalMaintainer
 	cp.Depends = pkg.Depends
+	cp.Relations = pkg.Relations
 	cp.Description = pkg.Description
 	for k, v := range pkg.Hashes {
 		cp.Hashes = append(cp.Hashes, Hash{
@@ -99,6 +196,7 @@ func Convert(pkg *DebPkg, mt string) *CommonPkg {
 			v,
 		})
 	}
+	cp.Files = pkg.Files
 	for _, l := range pkg.Licences {
 		if l != nil {
 			cp.License = append(cp.License, RemoveDuplicates(l.Names)...)
@@ -161,10 +259,171 @@ func (p *DebPkg) Merge(n *DebPkg) {
 	if len(n.Suggests) != 0 {
 		p.Suggests = n.Suggests
 	}
+	if len(n.Relations) != 0 {
+		p.Relations = n.Relations
+	}
+	if len(n.PreDepends) != 0 {
+		p.PreDepends = n.PreDepends
+	}
+	if len(n.Recommends) != 0 {
+		p.Recommends = n.Recommends
+	}
+	if len(n.Enhances) != 0 {
+		p.Enhances = n.Enhances
+	}
+	if len(n.Provides) != 0 {
+		p.Provides = n.Provides
+	}
+	if len(n.Conflicts) != 0 {
+		p.Conflicts = n.Conflicts
+	}
+	if len(n.Breaks) != 0 {
+		p.Breaks = n.Breaks
+	}
+	if len(n.Replaces) != 0 {
+		p.Replaces = n.Replaces
+	}
+	if len(n.BuiltUsing) != 0 {
+		p.BuiltUsing = n.BuiltUsing
+	}
+	if n.MultiArch != "" {
+		p.MultiArch = n.MultiArch
+	}
+	if n.Essential {
+		p.Essential = n.Essential
+	}
+	if len(n.Extra) != 0 {
+		p.Extra = n.Extra
+	}
 	if len(n.Hashes) != 0 {
 		p.Hashes = n.Hashes
 	}
+	if len(n.Files) != 0 {
+		p.Files = n.Files
+	}
+	if len(n.Manifest) != 0 {
+		p.Manifest = n.Manifest
+	}
+	if len(n.Conffiles) != 0 {
+		p.Conffiles = n.Conffiles
+	}
+	if len(n.Shlibs) != 0 {
+		p.Shlibs = n.Shlibs
+	}
+	if len(n.Triggers) != 0 {
+		p.Triggers = n.Triggers
+	}
+	if len(n.Scripts) != 0 {
+		p.Scripts = n.Scripts
+	}
 	if len(n.Licences) != 0 {
 		p.Licences = n.Licences
 	}
+	if n.Copyright != nil {
+		p.Copyright = n.Copyright
+	}
+}
+
+// PacmanPkg Arch Linux、Manjaro等使用的pacman包，字段来自.PKGINFO
+// Backup为升级时保留的配置文件，OptDepends保留原始的"名称: 说明"形式
+type PacmanPkg struct {
+	OS           string            `json:"os,omitempty"`
+	PkgName      string            `json:"pkgname,omitempty"`
+	PkgBase      string            `json:"pkgbase,omitempty"`
+	PkgVer       string            `json:"pkgver,omitempty"`
+	PkgDesc      string            `json:"pkgdesc,omitempty"`
+	URL          string            `json:"url,omitempty"`
+	BuildDate    string            `json:"builddate,omitempty"`
+	Packager     string            `json:"packager,omitempty"`
+	Size         string            `json:"size,omitempty"`
+	Arch         string            `json:"arch,omitempty"`
+	License      []string          `json:"license,omitempty"`
+	Groups       []string          `json:"groups,omitempty"`
+	Depends      []string          `json:"depends,omitempty"`
+	OptDepends   []string          `json:"optdepends,omitempty"`
+	MakeDepends  []string          `json:"makedepends,omitempty"`
+	CheckDepends []string          `json:"checkdepends,omitempty"`
+	Provides     []string          `json:"provides,omitempty"`
+	Conflicts    []string          `json:"conflicts,omitempty"`
+	Replaces     []string          `json:"replaces,omitempty"`
+	Backup       []string          `json:"backup,omitempty"`
+	Relations    []*Relation       `json:"relations,omitempty"`
+	Build        *PacmanBuild      `json:"build,omitempty"`
+	Hashes       []Hash            `json:"hashes,omitempty"`
+	Files        []*File           `json:"files,omitempty"`
+	Manifest     []*PacmanFile     `json:"manifest,omitempty"`
+	Mismatches   []*DigestMismatch `json:"mismatches,omitempty"`
+	Index        *IndexMeta        `json:"index,omitempty"`
+}
+
+// PacmanBuild .BUILDINFO中记录的构建环境，Installed为构建时已安装的包(名称-版本-架构)
+type PacmanBuild struct {
+	Format         string   `json:"format,omitempty"`
+	PkgbuildSHA256 string   `json:"pkgbuild_sha256sum,omitempty"`
+	Packager       string   `json:"packager,omitempty"`
+	BuildDate      string   `json:"builddate,omitempty"`
+	BuildDir       string   `json:"builddir,omitempty"`
+	StartDir       string   `json:"startdir,omitempty"`
+	BuildTool      string   `json:"buildtool,omitempty"`
+	BuildToolVer   string   `json:"buildtoolver,omitempty"`
+	BuildEnv       []string `json:"buildenv,omitempty"`
+	Options        []string `json:"options,omitempty"`
+	Installed      []string `json:"installed,omitempty"`
+}
+
+// PacmanFile .MTREE中的一条记录，Type为file、dir或link，Mode为八进制的权限位
+type PacmanFile struct {
+	Path   string `json:"path"`
+	Type   string `json:"type"`
+	Size   int64  `json:"size,omitempty"`
+	Mode   string `json:"mode,omitempty"`
+	MD5    string `json:"md5,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+	Link   string `json:"link,omitempty"`
+}
+
+// SnapPkg snap包，字段来自meta/snap.yaml，Manager固定为snap
+// Apps为应用名，Plugs、Slots为snap及其应用声明的接口，StagePackages为snapcraft的
+// snap/manifest.yaml中记录的打包进snap的发行版包(名称=版本)
+type SnapPkg struct {
+	Manager       string     `json:"manager"`
+	Name          string     `json:"name,omitempty"`
+	Version       string     `json:"version,omitempty"`
+	Title         string     `json:"title,omitempty"`
+	Summary       string     `json:"summary,omitempty"`
+	Description   string     `json:"description,omitempty"`
+	Type          string     `json:"type,omitempty"`
+	Base          string     `json:"base,omitempty"`
+	Grade         string     `json:"grade,omitempty"`
+	Confinement   string     `json:"confinement,omitempty"`
+	License       string     `json:"license,omitempty"`
+	Architectures []string   `json:"architectures,omitempty"`
+	Apps          []string   `json:"apps,omitempty"`
+	Plugs         []string   `json:"plugs,omitempty"`
+	Slots         []string   `json:"slots,omitempty"`
+	StagePackages []string   `json:"stagePackages,omitempty"`
+	Hashes        []Hash     `json:"hashes,omitempty"`
+	Files         []*File    `json:"files,omitempty"`
+	Index         *IndexMeta `json:"index,omitempty"`
+}
+
+// AppImagePkg AppImage，字段来自根目录的.desktop文件及AppStream的metainfo，Manager固定为appimage
+// Architecture为runtime的ELF架构，Offset为squashfs在文件中的偏移
+type AppImagePkg struct {
+	Manager      string     `json:"manager"`
+	Name         string     `json:"name,omitempty"`
+	Version      string     `json:"version,omitempty"`
+	Architecture string     `json:"architecture,omitempty"`
+	Summary      string     `json:"summary,omitempty"`
+	Description  string     `json:"description,omitempty"`
+	Homepage     string     `json:"homepage,omitempty"`
+	License      string     `json:"license,omitempty"`
+	AppID        string     `json:"appId,omitempty"`
+	Exec         string     `json:"exec,omitempty"`
+	Categories   []string   `json:"categories,omitempty"`
+	Desktop      string     `json:"desktop,omitempty"`
+	Offset       int64      `json:"offset"`
+	Hashes       []Hash     `json:"hashes,omitempty"`
+	Files        []*File    `json:"files,omitempty"`
+	Index        *IndexMeta `json:"index,omitempty"`
 }
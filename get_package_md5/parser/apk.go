@@ -2,6 +2,7 @@ package parser
 
 import (
 	"bufio"
+	"context"
 	"get_package_md5/model"
 	"get_package_md5/unarchiver"
 	"get_package_md5/utils"
@@ -17,41 +18,38 @@ func NewApkParser() *Apk {
 	return &Apk{}
 }
 
-func (p *Apk) Parse(i io.Reader, out string) error {
+func (p *Apk) Parse(ctx context.Context, i io.Reader, meta *model.IndexMeta) (interface{}, error) {
 	pkg := new(model.ApkPkg)
-	if err := unarchiver.ReadTarGzip(i, func(n string, r io.Reader) error {
+	if err := unarchiver.ReadTarGzip(unarchiver.WithContext(ctx, i), func(n string, r io.Reader) error {
 		if isPkgInfo(n) {
 			err := parsePkgInfo(r, pkg)
 			if err != nil {
 				return errors2.WithMessagef(err, "parse pkginfo")
 			}
-		}
-		data, err := io.ReadAll(r)
-		if err != nil {
-			return err
+			return nil
 		}
 		if utils.NoBinary(n) {
 			return nil
 		}
-		ok, val, e := utils.CheckElf(data)
+		f, e := hashElf(n, r, unarchiver.Info(r))
 		if e != nil {
 			return errors2.WithMessagef(e, "calculate hash")
 		}
-		if !ok {
+		if f == nil {
 			return nil
 		}
 		pkg.Hashes = append(pkg.Hashes, model.Hash{
-			Key:   val,
+			Key:   f.MD5,
 			Value: n,
 		})
+		pkg.Files = append(pkg.Files, f)
 		return nil
 	}); err != nil {
-		return err
+		return nil, err
 	}
-	if err := utils.SaveJson(pkg, out); err != nil {
-		return errors2.WithMessagef(err, "save json")
-	}
-	return nil
+	pkg.OS = meta.OS
+	pkg.Index = meta
+	return pkg, nil
 }
 
 func (p *Apk) Check(n string) bool {
@@ -64,12 +62,13 @@ func isPkgInfo(n string) bool {
 
 func parsePkgInfo(r io.Reader, pkg *model.ApkPkg) error {
 	sc := bufio.NewScanner(r)
+	// last 上一个字段，没有"="的行为其续行
+	last := ""
 	for sc.Scan() {
 		if strings.HasPrefix(sc.Text(), "#") {
 			continue
 		}
 		line := strings.TrimSpace(sc.Text())
-		last := ""
 		if strings.Contains(line, "=") {
 			kvs := strings.SplitN(line, "=", 2)
 			val := kvs[1]
@@ -100,7 +99,16 @@ func parsePkgInfo(r io.Reader, pkg *model.ApkPkg) error {
 				last = "license"
 			case "depend":
 				pkg.Depend = append(pkg.Depend, val)
+				if rel := apkRelation("depends", val); rel != nil {
+					pkg.Relations = append(pkg.Relations, rel)
+				}
 				last = "depend"
+			case "provides":
+				pkg.Provides = append(pkg.Provides, val)
+				if rel := apkRelation("provides", val); rel != nil {
+					pkg.Relations = append(pkg.Relations, rel)
+				}
+				last = "provides"
 			case "builddate":
 				pkg.BuildDate = val
 				last = "builddate"
@@ -110,6 +118,14 @@ func parsePkgInfo(r io.Reader, pkg *model.ApkPkg) error {
 			case "origin":
 				pkg.Origin = val
 				last = "origin"
+			case "replaces":
+				pkg.Replaces = val
+				if rel := apkRelation("replaces", val); rel != nil {
+					pkg.Relations = append(pkg.Relations, rel)
+				}
+				last = "replaces"
+			default:
+				last = ""
 			}
 		} else {
 			if last != "pkgdesc" {
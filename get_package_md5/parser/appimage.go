@@ -0,0 +1,217 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"debug/elf"
+	"encoding/binary"
+	"encoding/xml"
+	"get_package_md5/model"
+	"get_package_md5/unarchiver"
+	"get_package_md5/utils"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type AppImage struct{}
+
+func NewAppImageParser() *AppImage {
+	return &AppImage{}
+}
+
+// appImageMagic ELF头部填充区(偏移8)中的AppImage标识，最后一字节为类型
+// 类型1为ISO 9660镜像，类型2为runtime之后紧跟squashfs
+var appImageMagic = []byte("AI")
+
+// desktopEntry .desktop文件[Desktop Entry]组中使用的字段
+type desktopEntry struct {
+	path       string
+	name       string
+	version    string
+	comment    string
+	exec       string
+	categories []string
+}
+
+// appStream AppStream的metainfo(旧称appdata)，版本取最新的release
+type appStream struct {
+	ID             string `xml:"id"`
+	ProjectLicense string `xml:"project_license"`
+	Summary        string `xml:"summary"`
+	URLs           []struct {
+		Type  string `xml:"type,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"url"`
+	Releases []struct {
+		Version string `xml:"version,attr"`
+	} `xml:"releases>release"`
+}
+
+func (p *AppImage) Parse(ctx context.Context, r io.Reader, meta *model.IndexMeta) (doc interface{}, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = errors.Errorf("panic err %s", e)
+		}
+	}()
+
+	ra, size, closer, err := utils.SpoolReader(unarchiver.WithContext(ctx, r))
+	if err != nil {
+		return nil, errors.WithMessagef(err, "read appimage")
+	}
+	defer closer.Close()
+
+	offset, err := appImageOffset(ra)
+	if err != nil {
+		return nil, err
+	}
+	sq := io.NewSectionReader(ra, offset, size-offset)
+	if !unarchiver.IsSquashfs(sq) {
+		return nil, errors.Errorf("no squashfs at offset %d", offset)
+	}
+
+	pkg := &model.AppImagePkg{Manager: "appimage", Offset: offset}
+	if info := utils.ElfInfo(ra); info != nil {
+		pkg.Architecture = info.Machine
+	}
+
+	// 根目录的.desktop文件描述该AppImage，没有时使用usr/share/applications中的
+	var root, app *desktopEntry
+	var stream *appStream
+	if err := readSquashfs(ctx, sq, func(n string, r io.Reader) error {
+		dir := path.Dir(n)
+		switch {
+		case strings.HasSuffix(n, ".desktop") && (dir == "." || dir == "usr/share/applications"):
+			d, err := parseDesktop(r)
+			if err != nil {
+				return errors.WithMessagef(err, "parse %s", n)
+			}
+			d.path = n
+			if dir == "." {
+				root = d
+			} else if app == nil {
+				app = d
+			}
+			return nil
+		case strings.HasSuffix(n, ".xml") && (dir == "usr/share/metainfo" || dir == "usr/share/appdata"):
+			if stream == nil {
+				s := new(appStream)
+				if err := xml.NewDecoder(r).Decode(s); err != nil {
+					return errors.WithMessagef(err, "parse %s", n)
+				}
+				stream = s
+			}
+			return nil
+		}
+		return hashBundled(n, r, &pkg.Hashes, &pkg.Files)
+	}); err != nil {
+		return nil, err
+	}
+
+	if root == nil {
+		root = app
+	}
+	if root != nil {
+		pkg.Desktop = root.path
+		pkg.Name = root.name
+		pkg.Version = root.version
+		pkg.Summary = root.comment
+		pkg.Exec = root.exec
+		pkg.Categories = root.categories
+	}
+	if stream != nil {
+		pkg.AppID = stream.ID
+		pkg.License = stream.ProjectLicense
+		if pkg.Summary == "" {
+			pkg.Summary = stream.Summary
+		}
+		for _, u := range stream.URLs {
+			if u.Type == "homepage" {
+				pkg.Homepage = strings.TrimSpace(u.Value)
+				break
+			}
+		}
+		if pkg.Version == "" && len(stream.Releases) > 0 {
+			pkg.Version = stream.Releases[0].Version
+		}
+	}
+	pkg.Index = meta
+	return pkg, nil
+}
+
+func (p *AppImage) Check(n string) bool {
+	return strings.HasSuffix(strings.ToLower(n), ".appimage")
+}
+
+// appImageOffset 计算squashfs在AppImage中的偏移，runtime为ELF，squashfs从其节头表的结尾开始
+func appImageOffset(r io.ReaderAt) (int64, error) {
+	var ident [elf.EI_NIDENT]byte
+	if _, err := r.ReadAt(ident[:], 0); err != nil {
+		return 0, errors.WithMessagef(err, "read elf header")
+	}
+	if !bytes.HasPrefix(ident[:], []byte(elf.ELFMAG)) {
+		return 0, errors.New("runtime is not an elf")
+	}
+	if bytes.Equal(ident[8:10], appImageMagic) && ident[10] == 1 {
+		return 0, errors.WithMessagef(ErrUnsupported, "type 1 appimage")
+	}
+
+	var order binary.ByteOrder = binary.LittleEndian
+	if elf.Data(ident[elf.EI_DATA]) == elf.ELFDATA2MSB {
+		order = binary.BigEndian
+	}
+	switch elf.Class(ident[elf.EI_CLASS]) {
+	case elf.ELFCLASS64:
+		var h elf.Header64
+		if err := binary.Read(io.NewSectionReader(r, 0, int64(binary.Size(h))), order, &h); err != nil {
+			return 0, errors.WithMessagef(err, "read elf header")
+		}
+		return int64(h.Shoff) + int64(h.Shentsize)*int64(h.Shnum), nil
+	case elf.ELFCLASS32:
+		var h elf.Header32
+		if err := binary.Read(io.NewSectionReader(r, 0, int64(binary.Size(h))), order, &h); err != nil {
+			return 0, errors.WithMessagef(err, "read elf header")
+		}
+		return int64(h.Shoff) + int64(h.Shentsize)*int64(h.Shnum), nil
+	}
+	return 0, errors.Errorf("invalid elf class %d", ident[elf.EI_CLASS])
+}
+
+// parseDesktop 读取.desktop文件的[Desktop Entry]组，忽略本地化的键(Name[zh_CN])
+func parseDesktop(r io.Reader) (*desktopEntry, error) {
+	d := new(desktopEntry)
+	group := ""
+	err := readLines(r, func(line string) {
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			group = line
+			return
+		}
+		if group != "[Desktop Entry]" {
+			return
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			return
+		}
+		v = strings.TrimSpace(v)
+		switch strings.TrimSpace(k) {
+		case "Name":
+			d.name = v
+		case "X-AppImage-Version":
+			d.version = v
+		case "Comment":
+			d.comment = v
+		case "Exec":
+			d.exec = v
+		case "Categories":
+			for _, c := range strings.Split(v, ";") {
+				if c = strings.TrimSpace(c); c != "" {
+					d.categories = append(d.categories, c)
+				}
+			}
+		}
+	})
+	return d, err
+}
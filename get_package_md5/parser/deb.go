@@ -1,12 +1,14 @@
 package parser
 
 import (
-	"bufio"
+	"bytes"
+	"context"
 	"fmt"
 	"get_package_md5/model"
 	"get_package_md5/unarchiver"
 	"get_package_md5/utils"
 	"io"
+	"path"
 	"strings"
 
 	errors2 "github.com/pkg/errors"
@@ -18,7 +20,7 @@ func NewDebParser() *Deb {
 	return &Deb{}
 }
 
-func (d *Deb) Parse(r io.Reader, out string) (err error) {
+func (d *Deb) Parse(ctx context.Context, r io.Reader, meta *model.IndexMeta) (doc interface{}, err error) {
 	defer func() {
 		if e := recover(); e != nil {
 			err = errors2.New("panic err")
@@ -26,7 +28,7 @@ func (d *Deb) Parse(r io.Reader, out string) (err error) {
 	}()
 
 	pkg := new(model.DebPkg)
-	if err := unarchiver.ReadAr(r, func(n string, r io.Reader) error {
+	if err := unarchiver.ReadAr(unarchiver.WithContext(ctx, r), func(n string, r io.Reader) error {
 		if IsControl(n) {
 			return ParseControl(n, r, pkg)
 		} else if IsData(n) {
@@ -34,12 +36,13 @@ func (d *Deb) Parse(r io.Reader, out string) (err error) {
 		}
 		return nil
 	}); err != nil {
-		return err
+		return nil, err
 	}
-	if err := utils.SaveJson(pkg, out); err != nil {
-		return errors2.WithMessagef(err, "save json")
-	}
-	return nil
+	checkMd5sums(pkg)
+	attributeLicenses(pkg)
+	pkg.OS = meta.OS
+	pkg.Index = meta
+	return pkg, nil
 }
 
 func (d *Deb) Check(n string) bool {
@@ -95,75 +98,119 @@ func ParseData(name string, r io.Reader, pkg *model.DebPkg) error {
 	})
 }
 
+// analyzeControlFile 按文件名解析control压缩包中的各个文件
 func analyzeControlFile(name string, r io.Reader, p *model.DebPkg) error {
-	if strings.Contains(name, "control") {
-		scanner := bufio.NewScanner(r)
-		dsValue := ""
-		for scanner.Scan() {
-			if dsValue != "" {
-				dsValue = dsValue + scanner.Text()
-				continue
-			}
-			i := strings.Index(scanner.Text(), ":")
-			if i == -1 {
-				continue
-			}
-			k := strings.TrimSpace(scanner.Text()[:i])
-			v := strings.TrimSpace(scanner.Text()[i+1:])
-			switch k {
-			case "Package":
-				p.Name = v
-			case "Source":
-				p.Source = v
-			case "Version":
-				p.Version = v
-			case "Architecture":
-				p.Architecture = v
-			case "Maintainer":
-				p.Maintainer = v
-			case "Original-Maintainer":
-				p.OriginalMaintainer = v
-			case "Installed-Size":
-				p.InstalledSize = v
-			case "Depends":
-				for _, dep := range strings.Split(v, ",") {
-					p.Depends = append(p.Depends, strings.TrimSpace(dep))
-				}
-			case "Suggests":
-				for _, dep := range strings.Split(v, ",") {
-					p.Suggests = append(p.Suggests, strings.TrimSpace(dep))
-				}
-			case "Section":
-				p.Section = v
-			case "Priority":
-				p.Priority = v
-			case "Homepage":
-				p.Homepage = v
-			}
-			if k == "Description" {
-				// description信息有多行，需特殊处理
-				dsValue = dsValue + v
-			}
-		}
-		if dsValue != "" {
-			p.Description = dsValue
+	switch n := path.Base(name); n {
+	case "control":
+		return parseControlFields(r, p)
+	case "md5sums":
+		return parseMd5sums(r, p)
+	case "conffiles":
+		return parseConffiles(r, p)
+	case "shlibs":
+		return parseShlibs(r, p)
+	case "triggers":
+		return parseTriggers(r, p)
+	default:
+		if maintainerScripts[n] {
+			return hashScript(n, r, p)
 		}
 	}
-
 	return nil
 }
 
+// parseControlFields 解析control文件，description保留原始的多行格式，未单独映射的字段记录在Extra中
+func parseControlFields(r io.Reader, p *model.DebPkg) error {
+	return ReadStanzas(r, func(s *Stanza) bool {
+		applyControlFields(s, p)
+		// control文件只有一段
+		return false
+	})
+}
+
+// applyControlFields 将control格式的一段(control文件或dpkg的status中的一条记录)映射到p
+func applyControlFields(s *Stanza, p *model.DebPkg) {
+	for _, f := range s.Fields {
+		v := f.Value
+		if kind := strings.ToLower(f.Name); debRelationKinds[kind] {
+			p.Relations = append(p.Relations, debRelations(kind, v)...)
+		}
+		switch strings.ToLower(f.Name) {
+		case "package":
+			p.Name = v
+		case "source":
+			p.Source = v
+		case "version":
+			p.Version = v
+		case "architecture":
+			p.Architecture = v
+		case "maintainer":
+			p.Maintainer = v
+		case "original-maintainer":
+			p.OriginalMaintainer = v
+		case "installed-size":
+			p.InstalledSize = v
+		case "section":
+			p.Section = v
+		case "priority":
+			p.Priority = v
+		case "homepage":
+			p.Homepage = v
+		case "description":
+			p.Description = v
+		case "multi-arch":
+			p.MultiArch = v
+		case "essential":
+			p.Essential = v == "yes"
+		case "depends":
+			p.Depends = s.List(f.Name)
+		case "pre-depends":
+			p.PreDepends = s.List(f.Name)
+		case "recommends":
+			p.Recommends = s.List(f.Name)
+		case "suggests":
+			p.Suggests = s.List(f.Name)
+		case "enhances":
+			p.Enhances = s.List(f.Name)
+		case "provides":
+			p.Provides = s.List(f.Name)
+		case "conflicts":
+			p.Conflicts = s.List(f.Name)
+		case "breaks":
+			p.Breaks = s.List(f.Name)
+		case "replaces":
+			p.Replaces = s.List(f.Name)
+		case "built-using":
+			p.BuiltUsing = s.List(f.Name)
+		default:
+			if p.Extra == nil {
+				p.Extra = map[string]string{}
+			}
+			p.Extra[f.Name] = v
+		}
+	}
+}
+
 // analyzeDataFile 解析data文件，获取elf文件hash值
 func analyzeDataFile(n string, r io.Reader, p *model.DebPkg) error {
 	n = strings.TrimPrefix(n, "./")
-	data, err := io.ReadAll(r)
-	if err != nil {
-		return errors2.Wrapf(err, "read %s", n)
-	}
-	l, _ := utils.CheckLicense(n, data)
-	if l != nil {
-		p.Licences = append(p.Licences, l)
-		return nil
+	info := unarchiver.Info(r)
+	// 只有文件名像许可证的文件需要读入内存识别，其余文件流式处理
+	if utils.IsLicenseFile(n) {
+		data, err := io.ReadAll(io.LimitReader(r, utils.MaxLicenseSize))
+		if err != nil {
+			return errors2.Wrapf(err, "read %s", n)
+		}
+		// 机器可读的copyright按DEP-5解析，自由格式的仍由licensecheck识别
+		if isDebCopyright(n) && isDep5(data) {
+			return analyzeCopyright(n, data, p)
+		}
+		l, _ := utils.CheckLicense(n, data)
+		if l != nil {
+			p.Licences = append(p.Licences, l)
+			return nil
+		}
+		r = io.MultiReader(bytes.NewReader(data), r)
 	}
 
 	if p.Hashes == nil {
@@ -172,11 +219,14 @@ func analyzeDataFile(n string, r io.Reader, p *model.DebPkg) error {
 	if utils.NoBinary(n) {
 		return nil
 	}
-	if ok, md5Val, err := utils.CheckElf(data); ok {
-		p.Hashes[md5Val] = n
-	} else if err != nil {
+	f, err := hashElf(n, r, info)
+	if err != nil {
 		return errors2.Wrapf(err, "check reader")
 	}
+	if f != nil {
+		p.Hashes[f.MD5] = n
+		p.Files = append(p.Files, f)
+	}
 	return nil
 }
 
@@ -0,0 +1,116 @@
+package parser
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// Field deb822中的一个字段，多行字段的续行以换行分隔，" ."表示的空行还原为空字符串行
+type Field struct {
+	Name  string
+	Value string
+}
+
+// Stanza deb822格式中的一段(control文件、Packages或Release中的一条记录)，保留字段的原始顺序
+type Stanza struct {
+	Fields []Field
+	index  map[string]int
+}
+
+// Get 返回字段的值，字段名不区分大小写，不存在时返回空字符串
+func (s *Stanza) Get(name string) string {
+	if i, ok := s.index[strings.ToLower(name)]; ok {
+		return s.Fields[i].Value
+	}
+	return ""
+}
+
+// Has 字段是否存在
+func (s *Stanza) Has(name string) bool {
+	_, ok := s.index[strings.ToLower(name)]
+	return ok
+}
+
+// List 按逗号切分关系类字段(Depends、Provides等)
+func (s *Stanza) List(name string) []string {
+	var list []string
+	for _, item := range strings.Split(s.Get(name), ",") {
+		if item = strings.Join(strings.Fields(item), " "); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func (s *Stanza) add(name, value string) {
+	if s.index == nil {
+		s.index = map[string]int{}
+	}
+	key := strings.ToLower(name)
+	// 重复的字段以后出现的为准
+	if i, ok := s.index[key]; ok {
+		s.Fields[i].Value = value
+		return
+	}
+	s.index[key] = len(s.Fields)
+	s.Fields = append(s.Fields, Field{Name: name, Value: value})
+}
+
+func (s *Stanza) appendLine(line string) {
+	if len(s.Fields) == 0 {
+		return
+	}
+	f := &s.Fields[len(s.Fields)-1]
+	// 续行去掉开头的一个空白，单独的"."表示空行
+	line = line[1:]
+	if strings.TrimSpace(line) == "." {
+		line = ""
+	}
+	// 首行为空的字段(如Release中的SHA256)直接以续行开始
+	if f.Value == "" {
+		f.Value = line
+		return
+	}
+	f.Value += "\n" + line
+}
+
+// ReadStanzas 逐段读取deb822格式的内容，段之间以空行分隔，do返回false时停止读取
+// 以空格或tab开头的行为上一字段的续行，以#开头的行为注释
+func ReadStanzas(r io.Reader, do func(s *Stanza) bool) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 4*1024*1024)
+
+	s := new(Stanza)
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			if len(s.Fields) > 0 {
+				if !do(s) {
+					return nil
+				}
+				s = new(Stanza)
+			}
+			continue
+		}
+		switch line[0] {
+		case '#':
+			continue
+		case ' ', '\t':
+			s.appendLine(line)
+			continue
+		}
+		i := strings.Index(line, ":")
+		if i == -1 {
+			continue
+		}
+		s.add(strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:]))
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	if len(s.Fields) > 0 {
+		do(s)
+	}
+	return nil
+}
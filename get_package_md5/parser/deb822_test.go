@@ -0,0 +1,123 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"get_package_md5/model"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 使用 go test ./parser -update 重新生成testdata中的golden文件
+var update = flag.Bool("update", false, "update golden files")
+
+// checkGolden 将v序列化为json后与testdata/<name>.golden比较
+func checkGolden(t *testing.T, name string, v interface{}) {
+	t.Helper()
+	got, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = append(got, '\n')
+
+	golden := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(golden, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s mismatch\ngot:\n%s\nwant:\n%s", golden, got, want)
+	}
+}
+
+func openTestdata(t *testing.T, name string) *os.File {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+func TestParseControlFields(t *testing.T) {
+	p := new(model.DebPkg)
+	if err := parseControlFields(openTestdata(t, "control"), p); err != nil {
+		t.Fatal(err)
+	}
+
+	// " ."还原为空行，续行只去掉开头的一个空格
+	want := "foo runtime library\n" +
+		"libfoo provides the runtime support for foo\n" +
+		"and is used by every foo frontend.\n" +
+		"\n" +
+		"This package contains the shared library.\n" +
+		"\n" +
+		" * indented list item"
+	if p.Description != want {
+		t.Errorf("description = %q, want %q", p.Description, want)
+	}
+	checkGolden(t, "control", p)
+}
+
+func TestReadStanzasPackages(t *testing.T) {
+	var pkgs []*model.DebPkg
+	if err := ReadStanzas(openTestdata(t, "Packages"), func(s *Stanza) bool {
+		p := new(model.DebPkg)
+		applyControlFields(s, p)
+		pkgs = append(pkgs, p)
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pkgs) != 2 {
+		t.Fatalf("got %d stanzas, want 2", len(pkgs))
+	}
+	if got := strings.Join(pkgs[0].Depends, "|"); got != "libc6 (>= 2.34)|libfoo1 (= 2.0-1)|foo-data" {
+		t.Errorf("folded depends = %q", got)
+	}
+	checkGolden(t, "Packages", pkgs)
+}
+
+func TestReadStanzasRelease(t *testing.T) {
+	var stanzas []*Stanza
+	if err := ReadStanzas(openTestdata(t, "Release"), func(s *Stanza) bool {
+		stanzas = append(stanzas, s)
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stanzas) != 1 {
+		t.Fatalf("got %d stanzas, want 1", len(stanzas))
+	}
+	s := stanzas[0]
+	// SHA256的首行为空，值直接从第一条校验值开始
+	sums := strings.Split(s.Get("sha256"), "\n")
+	if len(sums) != 3 || !strings.HasPrefix(sums[0], "3957f28d") {
+		t.Errorf("sha256 = %q", sums)
+	}
+	checkGolden(t, "Release", s.Fields)
+}
+
+func TestParsePacmanInfo(t *testing.T) {
+	p := new(model.PacmanPkg)
+	if err := parsePacmanInfo(openTestdata(t, "PKGINFO"), p); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "A foo tool\nthat spans two lines"; p.PkgDesc != want {
+		t.Errorf("pkgdesc = %q, want %q", p.PkgDesc, want)
+	}
+	checkGolden(t, "PKGINFO", p)
+}
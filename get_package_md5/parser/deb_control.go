@@ -0,0 +1,121 @@
+package parser
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"get_package_md5/model"
+	"io"
+	"strings"
+
+	errors2 "github.com/pkg/errors"
+)
+
+// maintainerScripts control压缩包中的维护脚本
+var maintainerScripts = map[string]bool{
+	"preinst":  true,
+	"postinst": true,
+	"prerm":    true,
+	"postrm":   true,
+	"config":   true,
+}
+
+// readLines 逐行读取，跳过空行及注释
+func readLines(r io.Reader, do func(line string)) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		do(line)
+	}
+	return sc.Err()
+}
+
+// parseMd5sums 解析md5sums，每行为"<md5>  <相对路径>"，包含非ELF文件
+func parseMd5sums(r io.Reader, p *model.DebPkg) error {
+	return readLines(r, func(line string) {
+		i := strings.IndexAny(line, " \t")
+		if i == -1 {
+			return
+		}
+		p.Manifest = append(p.Manifest, &model.DebFile{
+			Path: strings.TrimPrefix(strings.TrimSpace(line[i:]), "./"),
+			MD5:  strings.ToLower(line[:i]),
+		})
+	})
+}
+
+// parseConffiles 解析conffiles，每行为一个绝对路径，新版dpkg可能带有remove-on-upgrade等前缀
+func parseConffiles(r io.Reader, p *model.DebPkg) error {
+	return readLines(r, func(line string) {
+		fields := strings.Fields(line)
+		p.Conffiles = append(p.Conffiles, fields[len(fields)-1])
+	})
+}
+
+// parseShlibs 解析shlibs，每行为"[type:] 库名 soname版本 依赖"
+func parseShlibs(r io.Reader, p *model.DebPkg) error {
+	return readLines(r, func(line string) {
+		s := new(model.Shlib)
+		fields := strings.Fields(line)
+		if strings.HasSuffix(fields[0], ":") {
+			s.Type = strings.TrimSuffix(fields[0], ":")
+			fields = fields[1:]
+		}
+		if len(fields) < 2 {
+			return
+		}
+		s.Library = fields[0]
+		s.Version = fields[1]
+		s.Depends = strings.Join(fields[2:], " ")
+		p.Shlibs = append(p.Shlibs, s)
+	})
+}
+
+// parseTriggers 解析triggers，每行为"<指令> <trigger名>"
+func parseTriggers(r io.Reader, p *model.DebPkg) error {
+	return readLines(r, func(line string) {
+		p.Triggers = append(p.Triggers, line)
+	})
+}
+
+// hashScript 计算维护脚本的摘要
+func hashScript(n string, r io.Reader, p *model.DebPkg) error {
+	m, s := md5.New(), sha256.New()
+	size, err := io.Copy(io.MultiWriter(m, s), r)
+	if err != nil {
+		return errors2.Wrapf(err, "read %s", n)
+	}
+	p.Scripts = append(p.Scripts, &model.Script{
+		Name:   n,
+		Size:   size,
+		MD5:    hex.EncodeToString(m.Sum(nil)),
+		SHA256: hex.EncodeToString(s.Sum(nil)),
+	})
+	return nil
+}
+
+// checkMd5sums 用md5sums校验计算出的ELF摘要，md5sums中没有记录的文件不校验
+func checkMd5sums(p *model.DebPkg) {
+	if len(p.Manifest) == 0 {
+		return
+	}
+	sums := make(map[string]string, len(p.Manifest))
+	for _, f := range p.Manifest {
+		sums[f.Path] = f.MD5
+	}
+	for _, f := range p.Files {
+		if sum, ok := sums[f.Path]; ok && sum != f.MD5 {
+			p.Mismatches = append(p.Mismatches, &model.DigestMismatch{
+				Path:     f.Path,
+				Reason:   "md5",
+				Expected: sum,
+				Actual:   f.MD5,
+			})
+		}
+	}
+}
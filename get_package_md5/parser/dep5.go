@@ -0,0 +1,307 @@
+package parser
+
+import (
+	"bytes"
+	"get_package_md5/model"
+	"get_package_md5/utils"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// dep5Prefix 机器可读copyright的第一段必须以Format字段开头
+const dep5Prefix = "format:"
+
+// debSpdx debian中常见的许可证简称到SPDX标识的映射，不含版本号的GNU系列许可证单独处理
+var debSpdx = map[string]string{
+	"expat":         "MIT",
+	"mit":           "MIT",
+	"isc":           "ISC",
+	"zlib":          "Zlib",
+	"bsd-2-clause":  "BSD-2-Clause",
+	"bsd-3-clause":  "BSD-3-Clause",
+	"bsd-4-clause":  "BSD-4-Clause",
+	"artistic":      "Artistic-1.0",
+	"artistic-1":    "Artistic-1.0",
+	"artistic-2":    "Artistic-2.0",
+	"artistic-2.0":  "Artistic-2.0",
+	"perl":          "Artistic-1.0-Perl OR GPL-1.0-or-later",
+	"cc0":           "CC0-1.0",
+	"cc0-1.0":       "CC0-1.0",
+	"public-domain": "LicenseRef-public-domain",
+	"unlicense":     "Unlicense",
+	"boost-1.0":     "BSL-1.0",
+	"bsl-1.0":       "BSL-1.0",
+	"openssl":       "OpenSSL",
+	"ssleay":        "OpenSSL",
+	"x11":           "X11",
+	"curl":          "curl",
+	"wtfpl":         "WTFPL",
+	"python-2.0":    "Python-2.0",
+	"psf-2":         "PSF-2.0",
+	"psf-2.0":       "PSF-2.0",
+	"apache-2":      "Apache-2.0",
+	"apache-2.0":    "Apache-2.0",
+	"mpl-1.1":       "MPL-1.1",
+	"mpl-2":         "MPL-2.0",
+	"mpl-2.0":       "MPL-2.0",
+	"epl-1.0":       "EPL-1.0",
+	"epl-2.0":       "EPL-2.0",
+	"cddl-1.0":      "CDDL-1.0",
+	"ofl-1.1":       "OFL-1.1",
+	"sil-ofl-1.1":   "OFL-1.1",
+	"lppl-1.3c":     "LPPL-1.3c",
+	"w3c":           "W3C",
+	"zpl-2.1":       "ZPL-2.1",
+	"cc-by-3.0":     "CC-BY-3.0",
+	"cc-by-4.0":     "CC-BY-4.0",
+	"cc-by-sa-3.0":  "CC-BY-SA-3.0",
+	"cc-by-sa-4.0":  "CC-BY-SA-4.0",
+	"gfdl-niv-1.2":  "GFDL-1.2-no-invariants-only",
+	"gfdl-niv-1.2+": "GFDL-1.2-no-invariants-or-later",
+	"gfdl-niv-1.3":  "GFDL-1.3-no-invariants-only",
+	"gfdl-niv-1.3+": "GFDL-1.3-no-invariants-or-later",
+}
+
+// debSpdxExceptions "with ... exception"中的例外到SPDX例外标识的映射
+var debSpdxExceptions = map[string]string{
+	"autoconf":  "Autoconf-exception-3.0",
+	"bison":     "Bison-exception-2.2",
+	"classpath": "Classpath-exception-2.0",
+	"font":      "Font-exception-2.0",
+	"gcc":       "GCC-exception-3.1",
+	"libtool":   "Libtool-exception",
+}
+
+// gnuLicenseRe GNU系列许可证，如GPL-2+、LGPL-2.1、AGPL-3.0+
+var gnuLicenseRe = regexp.MustCompile(`^(?i)(a?gpl|lgpl|gfdl)(?:-(\d+(?:\.\d+)?))?(\+)?$`)
+
+// licenseRefRe LicenseRef中允许的字符
+var licenseRefRe = regexp.MustCompile(`[^A-Za-z0-9.-]+`)
+
+// isDep5 判断copyright文件是否声明了Format，即机器可读格式
+func isDep5(data []byte) bool {
+	data = bytes.TrimLeft(data, " \t\r\n")
+	return len(data) >= len(dep5Prefix) && strings.EqualFold(string(data[:len(dep5Prefix)]), dep5Prefix)
+}
+
+// isDebCopyright /usr/share/doc/<包名>/copyright
+func isDebCopyright(n string) bool {
+	n = strings.TrimPrefix(path.Clean("/"+n), "/")
+	return path.Base(n) == "copyright" && path.Dir(path.Dir(n)) == "usr/share/doc"
+}
+
+// parseDep5 解析机器可读的copyright，第一段为头部，之后为Files段及独立的License段
+// 独立License段中的全文用于识别Files段中非标准的许可证简称
+func parseDep5(n string, data []byte) (*model.Copyright, error) {
+	c := &model.Copyright{Path: n}
+	texts := map[string]string{}
+	first := true
+	if err := ReadStanzas(bytes.NewReader(data), func(s *Stanza) bool {
+		if first {
+			first = false
+			c.Format = s.Get("Format")
+			c.Upstream = s.Get("Upstream-Name")
+			c.Source = s.Get("Source")
+			c.Copyright = s.Get("Copyright")
+			c.License, _ = splitLicense(s.Get("License"))
+			return true
+		}
+		name, text := splitLicense(s.Get("License"))
+		if text != "" && name != "" {
+			texts[strings.ToLower(name)] = text
+		}
+		if !s.Has("Files") {
+			return true
+		}
+		c.Files = append(c.Files, &model.CopyrightFiles{
+			Patterns:  strings.Fields(s.Get("Files")),
+			Copyright: s.Get("Copyright"),
+			Raw:       name,
+		})
+		return true
+	}); err != nil {
+		return nil, err
+	}
+
+	c.License = normalizeLicense(c.License, texts)
+	for _, f := range c.Files {
+		f.License = normalizeLicense(f.Raw, texts)
+	}
+	return c, nil
+}
+
+// splitLicense License字段的第一行为许可证简称表达式，其余为许可证全文
+func splitLicense(v string) (name, text string) {
+	name, text, _ = strings.Cut(v, "\n")
+	return strings.TrimSpace(name), strings.TrimSpace(text)
+}
+
+// normalizeLicense 将debian的许可证表达式转换为SPDX表达式
+// or、and、with分别转换为OR、AND、WITH，逗号视为分隔符
+func normalizeLicense(expr string, texts map[string]string) string {
+	tokens := strings.Fields(strings.NewReplacer(",", " ", "(", " ( ", ")", " ) ").Replace(expr))
+	var out []string
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		switch strings.ToLower(t) {
+		case "or", "and":
+			out = append(out, strings.ToUpper(t))
+		case "with":
+			// with <name> exception
+			var words []string
+			for i+1 < len(tokens) && tokens[i+1] != ")" {
+				i++
+				if strings.EqualFold(tokens[i], "exception") {
+					break
+				}
+				words = append(words, tokens[i])
+			}
+			out = append(out, "WITH", spdxException(strings.Join(words, "-")))
+		case "(", ")":
+			out = append(out, t)
+		default:
+			id := spdxLicense(t, texts)
+			if strings.Contains(id, " ") {
+				id = "(" + id + ")"
+			}
+			out = append(out, id)
+		}
+	}
+	return strings.ReplaceAll(strings.ReplaceAll(strings.Join(out, " "), "( ", "("), " )", ")")
+}
+
+// spdxLicense 单个许可证简称到SPDX标识，无法识别时尝试用对应的全文识别，否则返回LicenseRef
+func spdxLicense(name string, texts map[string]string) string {
+	key := strings.ToLower(name)
+	if id, ok := debSpdx[key]; ok {
+		return id
+	}
+	if m := gnuLicenseRe.FindStringSubmatch(name); m != nil {
+		family, version, later := strings.ToUpper(m[1]), m[2], m[3] != ""
+		if version == "" {
+			// 未指明版本时为任意版本
+			version, later = "1", true
+			if family == "LGPL" {
+				version = "2"
+			} else if family == "AGPL" {
+				version = "3"
+			} else if family == "GFDL" {
+				version = "1.1"
+			}
+		}
+		if !strings.Contains(version, ".") {
+			version += ".0"
+		}
+		if later {
+			return family + "-" + version + "-or-later"
+		}
+		return family + "-" + version + "-only"
+	}
+	if text, ok := texts[key]; ok {
+		if l := utils.ScanLicense(name, []byte(text)); l != nil && len(l.Names) == 1 {
+			return l.Names[0]
+		}
+	}
+	return "LicenseRef-" + strings.Trim(licenseRefRe.ReplaceAllString(name, "-"), "-")
+}
+
+func spdxException(name string) string {
+	if id, ok := debSpdxExceptions[strings.ToLower(name)]; ok {
+		return id
+	}
+	return "LicenseRef-" + strings.Trim(licenseRefRe.ReplaceAllString(name, "-"), "-") + "-exception"
+}
+
+// licenseIDs 表达式中出现的许可证标识，去掉运算符、括号及WITH之后的例外
+func licenseIDs(expr string) []string {
+	var ids []string
+	tokens := strings.Fields(strings.NewReplacer("(", " ", ")", " ").Replace(expr))
+	for i := 0; i < len(tokens); i++ {
+		switch tokens[i] {
+		case "OR", "AND":
+			continue
+		case "WITH":
+			i++
+			continue
+		}
+		ids = append(ids, tokens[i])
+	}
+	return ids
+}
+
+// dep5Match 匹配Files中的模式，*匹配任意字符(包括/)，?匹配单个字符，反斜杠用于转义
+func dep5Match(pattern, n string) bool {
+	var b strings.Builder
+	b.WriteString("^")
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch c := runes[i]; c {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		case '\\':
+			if i+1 < len(runes) {
+				i++
+				b.WriteString(regexp.QuoteMeta(string(runes[i])))
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return false
+	}
+	return re.MatchString(n)
+}
+
+// copyrightLicense 文件对应的许可证，以最后一个匹配的Files段为准，均不匹配时使用头部的License
+func copyrightLicense(c *model.Copyright, n string) string {
+	n = strings.TrimPrefix(path.Clean("/"+n), "/")
+	for i := len(c.Files) - 1; i >= 0; i-- {
+		for _, p := range c.Files[i].Patterns {
+			if dep5Match(strings.TrimPrefix(p, "./"), n) {
+				return c.Files[i].License
+			}
+		}
+	}
+	return c.License
+}
+
+// attributeLicenses 根据DEP-5 copyright为包内的ELF文件标注许可证
+func attributeLicenses(p *model.DebPkg) {
+	if p.Copyright == nil {
+		return
+	}
+	for _, f := range p.Files {
+		f.License = copyrightLicense(p.Copyright, f.Path)
+	}
+}
+
+// analyzeCopyright 解析机器可读的copyright，同时将出现的许可证记入Licences，便于与原有结果合并
+// 同一个包中有多个copyright时以包名对应的目录为准
+func analyzeCopyright(n string, data []byte, p *model.DebPkg) error {
+	c, err := parseDep5(n, data)
+	if err != nil {
+		return err
+	}
+	if p.Copyright != nil && path.Base(path.Dir(n)) != p.Name {
+		return nil
+	}
+	p.Copyright = c
+
+	var names []string
+	if c.License != "" {
+		names = append(names, licenseIDs(c.License)...)
+	}
+	for _, f := range c.Files {
+		names = append(names, licenseIDs(f.License)...)
+	}
+	if names = model.RemoveDuplicates(names); len(names) != 0 {
+		p.Licences = append(p.Licences, &model.License{Names: names, Per: 100, Path: n})
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"get_package_md5/model"
+	"get_package_md5/unarchiver"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// arMagic ar格式的文件头，较新的opkg-build生成ar格式的ipk，旧版本及部分SDK生成tar.gz格式
+var arMagic = []byte("!<arch>\n")
+
+type Ipk struct{}
+
+func NewIpkParser() *Ipk {
+	return &Ipk{}
+}
+
+// Parse ipk与deb结构相同，外层为ar或tar.gz，其中包含control.tar.gz与data.tar.gz，control字段与deb一致
+func (i *Ipk) Parse(ctx context.Context, r io.Reader, meta *model.IndexMeta) (doc interface{}, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = errors.Errorf("panic err %s", e)
+		}
+	}()
+
+	br := bufio.NewReader(unarchiver.WithContext(ctx, r))
+	head, _ := br.Peek(len(arMagic))
+	readFunc := unarchiver.ReadTarGzip
+	if bytes.Equal(head, arMagic) {
+		readFunc = unarchiver.ReadAr
+	}
+
+	pkg := new(model.DebPkg)
+	if err := readFunc(br, func(n string, r io.Reader) error {
+		// GNU ar生成的成员名以/结尾
+		n = strings.TrimSuffix(n, "/")
+		if IsControl(n) {
+			return ParseControl(n, r, pkg)
+		} else if IsData(n) {
+			return ParseData(n, r, pkg)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	checkMd5sums(pkg)
+	attributeLicenses(pkg)
+	// OpenWrt的control中带有License字段，多个许可证以空格或逗号分隔
+	if l := pkg.Extra["License"]; l != "" {
+		pkg.Licences = append(pkg.Licences, &model.License{
+			Names: strings.Fields(strings.ReplaceAll(l, ",", " ")),
+			Per:   100,
+			Path:  "control",
+		})
+	}
+	pkg.OS = meta.OS
+	pkg.Index = meta
+	return pkg, nil
+}
+
+func (i *Ipk) Check(n string) bool {
+	return strings.HasSuffix(n, ".ipk")
+}
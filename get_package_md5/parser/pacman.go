@@ -0,0 +1,303 @@
+package parser
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"get_package_md5/model"
+	"get_package_md5/unarchiver"
+	"get_package_md5/utils"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type Pacman struct{}
+
+func NewPacmanParser() *Pacman {
+	return &Pacman{}
+}
+
+// pacmanReaders 按包的后缀选择解压方式，makepkg默认使用zstd，旧包为xz
+var pacmanReaders = map[string]unarchiver.ReadFunc{
+	".pkg.tar.zst": unarchiver.ReadTarZst,
+	".pkg.tar.xz":  unarchiver.ReadTarXz,
+	".pkg.tar.gz":  unarchiver.ReadTarGzip,
+	".pkg.tar.bz2": unarchiver.ReadTarBz2,
+	".pkg.tar":     unarchiver.ReadTar,
+}
+
+func (p *Pacman) Parse(ctx context.Context, r io.Reader, meta *model.IndexMeta) (doc interface{}, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = errors.Errorf("panic err %s", e)
+		}
+	}()
+
+	readFunc := pacmanReader(meta.Path)
+	if readFunc == nil {
+		return nil, errors.WithMessagef(ErrUnsupported, "pacman package %s", meta.Path)
+	}
+
+	pkg := new(model.PacmanPkg)
+	if err := readFunc(unarchiver.WithContext(ctx, r), func(n string, r io.Reader) error {
+		n = strings.TrimPrefix(n, "./")
+		switch n {
+		case ".PKGINFO":
+			return errors.WithMessagef(parsePacmanInfo(r, pkg), "parse .PKGINFO")
+		case ".BUILDINFO":
+			return errors.WithMessagef(parseBuildInfo(r, pkg), "parse .BUILDINFO")
+		case ".MTREE":
+			return errors.WithMessagef(parseMtree(r, pkg), "parse .MTREE")
+		}
+		// .INSTALL、.CHANGELOG等元数据文件
+		if strings.HasPrefix(n, ".") || utils.NoBinary(n) {
+			return nil
+		}
+		f, err := hashElf(n, r, unarchiver.Info(r))
+		if err != nil {
+			return errors.WithMessagef(err, "calculate hash")
+		}
+		if f == nil {
+			return nil
+		}
+		pkg.Hashes = append(pkg.Hashes, model.Hash{Key: f.MD5, Value: n})
+		pkg.Files = append(pkg.Files, f)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	checkMtree(pkg)
+	pkg.OS = meta.OS
+	pkg.Index = meta
+	return pkg, nil
+}
+
+func (p *Pacman) Check(n string) bool {
+	return pacmanReader(n) != nil
+}
+
+func pacmanReader(n string) unarchiver.ReadFunc {
+	for suffix, readFunc := range pacmanReaders {
+		if strings.HasSuffix(n, suffix) {
+			return readFunc
+		}
+	}
+	return nil
+}
+
+// readKeyValues 读取.PKGINFO、.BUILDINFO，每行为"key = value"，可重复的字段出现多次
+// pkgdesc等值中包含换行时，之后的行没有"key ="前缀，拼接到上一字段
+func readKeyValues(r io.Reader, do func(k, v string)) error {
+	var key, value string
+	err := readLines(r, func(line string) {
+		k, v, ok := strings.Cut(line, "=")
+		k = strings.TrimSpace(k)
+		if !ok || strings.ContainsAny(k, " \t") {
+			if key != "" {
+				value += "\n" + line
+			}
+			return
+		}
+		if key != "" {
+			do(key, value)
+		}
+		key, value = k, strings.TrimSpace(v)
+	})
+	if key != "" {
+		do(key, value)
+	}
+	return err
+}
+
+func parsePacmanInfo(r io.Reader, pkg *model.PacmanPkg) error {
+	return readKeyValues(r, func(k, v string) {
+		switch k {
+		case "pkgname":
+			pkg.PkgName = v
+		case "pkgbase":
+			pkg.PkgBase = v
+		case "pkgver":
+			pkg.PkgVer = v
+		case "pkgdesc":
+			pkg.PkgDesc = v
+		case "url":
+			pkg.URL = v
+		case "builddate":
+			pkg.BuildDate = v
+		case "packager":
+			pkg.Packager = v
+		case "size":
+			pkg.Size = v
+		case "arch":
+			pkg.Arch = v
+		case "license":
+			pkg.License = append(pkg.License, v)
+		case "group":
+			pkg.Groups = append(pkg.Groups, v)
+		case "backup":
+			pkg.Backup = append(pkg.Backup, v)
+		case "depend":
+			pkg.Depends = append(pkg.Depends, v)
+		case "optdepend":
+			pkg.OptDepends = append(pkg.OptDepends, v)
+		case "makedepend":
+			pkg.MakeDepends = append(pkg.MakeDepends, v)
+		case "checkdepend":
+			pkg.CheckDepends = append(pkg.CheckDepends, v)
+		case "provides":
+			pkg.Provides = append(pkg.Provides, v)
+		case "conflict":
+			pkg.Conflicts = append(pkg.Conflicts, v)
+		case "replaces":
+			pkg.Replaces = append(pkg.Replaces, v)
+		}
+		if kind, ok := pacmanRelationKinds[k]; ok {
+			if rel := pacmanRelation(kind, v); rel != nil {
+				pkg.Relations = append(pkg.Relations, rel)
+			}
+		}
+	})
+}
+
+func parseBuildInfo(r io.Reader, pkg *model.PacmanPkg) error {
+	b := new(model.PacmanBuild)
+	pkg.Build = b
+	return readKeyValues(r, func(k, v string) {
+		switch k {
+		case "format":
+			b.Format = v
+		case "pkgbuild_sha256sum":
+			b.PkgbuildSHA256 = v
+		case "packager":
+			b.Packager = v
+		case "builddate":
+			b.BuildDate = v
+		case "builddir":
+			b.BuildDir = v
+		case "startdir":
+			b.StartDir = v
+		case "buildtool":
+			b.BuildTool = v
+		case "buildtoolver":
+			b.BuildToolVer = v
+		case "buildenv":
+			b.BuildEnv = append(b.BuildEnv, v)
+		case "options":
+			b.Options = append(b.Options, v)
+		case "installed":
+			b.Installed = append(b.Installed, v)
+		}
+	})
+}
+
+// parseMtree 解析gzip压缩的.MTREE，/set设置之后条目的默认属性，/unset取消
+// 条目形如"./usr/bin/foo time=... mode=755 size=... md5digest=... sha256digest=..."
+func parseMtree(r io.Reader, pkg *model.PacmanPkg) error {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gr.Close()
+
+	defaults := map[string]string{}
+	sc := bufio.NewScanner(gr)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		switch fields[0] {
+		case "/set":
+			for _, kv := range fields[1:] {
+				if k, v, ok := strings.Cut(kv, "="); ok {
+					defaults[k] = v
+				}
+			}
+			continue
+		case "/unset":
+			for _, k := range fields[1:] {
+				delete(defaults, k)
+			}
+			continue
+		}
+
+		n := strings.TrimPrefix(unescapeMtree(fields[0]), "./")
+		if n == "." || strings.HasPrefix(n, ".") {
+			continue
+		}
+		attrs := make(map[string]string, len(defaults)+len(fields))
+		for k, v := range defaults {
+			attrs[k] = v
+		}
+		for _, kv := range fields[1:] {
+			if k, v, ok := strings.Cut(kv, "="); ok {
+				attrs[k] = v
+			}
+		}
+		f := &model.PacmanFile{
+			Path:   n,
+			Type:   attrs["type"],
+			Mode:   attrs["mode"],
+			MD5:    attrs["md5digest"],
+			SHA256: attrs["sha256digest"],
+			Link:   unescapeMtree(attrs["link"]),
+		}
+		f.Size, _ = strconv.ParseInt(attrs["size"], 10, 64)
+		pkg.Manifest = append(pkg.Manifest, f)
+	}
+	return sc.Err()
+}
+
+// unescapeMtree 还原mtree中以\ooo表示的字符(如空格为\040)
+func unescapeMtree(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// checkMtree 将计算出的ELF摘要与.MTREE中的记录比对
+func checkMtree(pkg *model.PacmanPkg) {
+	if len(pkg.Manifest) == 0 {
+		return
+	}
+	entries := make(map[string]*model.PacmanFile, len(pkg.Manifest))
+	for _, f := range pkg.Manifest {
+		entries[f.Path] = f
+	}
+	for _, f := range pkg.Files {
+		entry, ok := entries[f.Path]
+		if !ok {
+			pkg.Mismatches = append(pkg.Mismatches, &model.DigestMismatch{Path: f.Path, Reason: "not in mtree"})
+			continue
+		}
+		switch {
+		case entry.Size != 0 && entry.Size != f.Size:
+			pkg.Mismatches = append(pkg.Mismatches, &model.DigestMismatch{
+				Path:     f.Path,
+				Reason:   "size",
+				Expected: strconv.FormatInt(entry.Size, 10),
+				Actual:   strconv.FormatInt(f.Size, 10),
+			})
+		case entry.SHA256 != "" && !strings.EqualFold(entry.SHA256, f.SHA256):
+			pkg.Mismatches = append(pkg.Mismatches, &model.DigestMismatch{Path: f.Path, Reason: "sha256", Expected: entry.SHA256, Actual: f.SHA256})
+		case entry.MD5 != "" && !strings.EqualFold(entry.MD5, f.MD5):
+			pkg.Mismatches = append(pkg.Mismatches, &model.DigestMismatch{Path: f.Path, Reason: "md5", Expected: entry.MD5, Actual: f.MD5})
+		}
+	}
+}
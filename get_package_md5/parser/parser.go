@@ -1,8 +1,41 @@
 package parser
 
-import "io"
+import (
+	"context"
+	"fmt"
+	"get_package_md5/model"
+	"get_package_md5/utils"
+	"io"
+	"io/fs"
+	"log"
+
+	"github.com/pkg/errors"
+)
+
+// ErrUnsupported 包或其中内容的格式暂不支持，与下载无关，重试同样会失败
+var ErrUnsupported = errors.New("unsupported format")
 
 type Parser interface {
-	Parse(r io.Reader, out string) error
+	// Parse 解析包并返回待保存的文档，meta为包的来源及仓库索引信息，会被挂到文档上
+	// ctx取消后读取r会失败，解析随之中断
+	Parse(ctx context.Context, r io.Reader, meta *model.IndexMeta) (interface{}, error)
 	Check(n string) bool
 }
+
+// hashElf 计算包内文件n的摘要，info为归档中的文件信息(可为nil)
+// 不是ELF或超过大小上限时返回nil
+func hashElf(n string, r io.Reader, info fs.FileInfo) (*model.File, error) {
+	f, err := utils.HashElf(r)
+	if errors.Is(err, utils.ErrMemberTooLarge) {
+		log.Printf("skip %s: %s\n", n, err)
+		return nil, nil
+	}
+	if err != nil || f == nil {
+		return nil, err
+	}
+	f.Path = n
+	if info != nil {
+		f.Mode = fmt.Sprintf("%04o", info.Mode().Perm())
+	}
+	return f, nil
+}
@@ -0,0 +1,225 @@
+package parser
+
+import (
+	"fmt"
+	"get_package_md5/model"
+	"regexp"
+	"strings"
+
+	"github.com/cavaliergopher/rpm"
+)
+
+// debRelationKinds control中的关系字段
+var debRelationKinds = map[string]bool{
+	"depends":     true,
+	"pre-depends": true,
+	"recommends":  true,
+	"suggests":    true,
+	"enhances":    true,
+	"provides":    true,
+	"conflicts":   true,
+	"breaks":      true,
+	"replaces":    true,
+	"built-using": true,
+}
+
+// debOperators deb中的<<、>>即严格小于、大于，旧格式的<、>等同于<=、>=
+var debOperators = map[string]string{
+	"<<": "<",
+	"<=": "<=",
+	"=":  "=",
+	">=": ">=",
+	">>": ">",
+	"<":  "<=",
+	">":  ">=",
+}
+
+// debDependencyRe 名称[:架构] [(运算符 版本)] [[架构列表]] [<构建profile>]
+var debDependencyRe = regexp.MustCompile(`^([^\s:(\[<]+)(?::(\S+))?\s*(?:\(\s*(<<|<=|>=|>>|=|<|>)\s*([^)\s]+)\s*\))?\s*(?:\[[^\]]*\])?\s*(?:<.*>)?$`)
+
+// debRelations 解析deb关系字段，字段值以逗号分隔，可选项以|分隔
+func debRelations(kind, value string) []*model.Relation {
+	var relations []*model.Relation
+	for _, item := range strings.Split(value, ",") {
+		rel := &model.Relation{Kind: kind}
+		for _, alt := range strings.Split(item, "|") {
+			alt = strings.Join(strings.Fields(alt), " ")
+			if alt == "" {
+				continue
+			}
+			m := debDependencyRe.FindStringSubmatch(alt)
+			if m == nil {
+				rel.Alternatives = append(rel.Alternatives, &model.Dependency{Name: alt})
+				continue
+			}
+			rel.Alternatives = append(rel.Alternatives, &model.Dependency{
+				Name:     m[1],
+				Arch:     m[2],
+				Operator: debOperators[m[3]],
+				Version:  m[4],
+			})
+		}
+		if len(rel.Alternatives) > 0 {
+			relations = append(relations, rel)
+		}
+	}
+	return relations
+}
+
+// apkOperators apk依赖中的运算符，按长度优先匹配，~与=~均为模糊匹配
+var apkOperators = []struct{ op, normalized string }{
+	{"<=", "<="},
+	{">=", ">="},
+	{"=~", "~"},
+	{"<", "<"},
+	{">", ">"},
+	{"=", "="},
+	{"~", "~"},
+}
+
+// apkRelation 解析apk的depend、provides条目，如so:libc.musl-x86_64.so.1、cmd:sh、busybox>=1.36、!foo
+// 以!开头的条目为冲突
+func apkRelation(kind, s string) *model.Relation {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	if strings.HasPrefix(s, "!") {
+		kind = "conflicts"
+		s = s[1:]
+	}
+
+	d := new(model.Dependency)
+	name := s
+	if i := strings.IndexAny(s, "<>=~"); i != -1 {
+		name = s[:i]
+		rest := s[i:]
+		for _, o := range apkOperators {
+			if strings.HasPrefix(rest, o.op) {
+				d.Operator = o.normalized
+				d.Version = rest[len(o.op):]
+				break
+			}
+		}
+	}
+	if i := strings.Index(name, ":"); i != -1 {
+		d.Namespace, name = name[:i], name[i+1:]
+	}
+	d.Name = name
+	return &model.Relation{Kind: kind, Alternatives: []*model.Dependency{d}}
+}
+
+// rpmRelations 转换rpm头部中的依赖，忽略rpmlib()内部依赖
+func rpmRelations(kind string, deps []rpm.Dependency) []*model.Relation {
+	var relations []*model.Relation
+	for _, dep := range deps {
+		if dep.Flags()&rpm.DepFlagRpmlib != 0 || strings.HasPrefix(dep.Name(), "rpmlib(") {
+			continue
+		}
+		d := rpmDependencyName(dep.Name())
+		d.Operator = rpmOperator(dep.Flags())
+		if d.Operator != "" {
+			d.Version = rpmEVR(dep)
+		}
+		relations = append(relations, &model.Relation{Kind: kind, Alternatives: []*model.Dependency{d}})
+	}
+	return relations
+}
+
+// rpmDependencyName 区分rpm依赖名称的类型
+// /bin/sh -> file，libc.so.6(GLIBC_2.2.5)(64bit) -> so，pkgconfig(zlib) -> pkgconfig
+func rpmDependencyName(name string) *model.Dependency {
+	if strings.HasPrefix(name, "/") {
+		return &model.Dependency{Name: name, Namespace: "file"}
+	}
+	i := strings.Index(name, "(")
+	if i <= 0 || !strings.HasSuffix(name, ")") {
+		return &model.Dependency{Name: name}
+	}
+	head, rest := name[:i], name[i:]
+	if strings.Contains(head, ".so") {
+		d := &model.Dependency{Name: head, Namespace: "so"}
+		if strings.HasSuffix(rest, "(64bit)") {
+			d.Arch = "64bit"
+		}
+		return d
+	}
+	return &model.Dependency{Name: strings.TrimSuffix(rest[1:], ")"), Namespace: head}
+}
+
+func rpmOperator(flags int) string {
+	switch {
+	case flags&rpm.DepFlagLesserOrEqual == rpm.DepFlagLesserOrEqual:
+		return "<="
+	case flags&rpm.DepFlagGreaterOrEqual == rpm.DepFlagGreaterOrEqual:
+		return ">="
+	case flags&rpm.DepFlagLesser != 0:
+		return "<"
+	case flags&rpm.DepFlagGreater != 0:
+		return ">"
+	case flags&rpm.DepFlagEqual != 0:
+		return "="
+	}
+	return ""
+}
+
+// rpmEVR 拼出[epoch:]version[-release]
+func rpmEVR(dep rpm.Dependency) string {
+	v := dep.Version()
+	if dep.Release() != "" {
+		v += "-" + dep.Release()
+	}
+	if dep.Epoch() > 0 {
+		v = fmt.Sprintf("%d:%s", dep.Epoch(), v)
+	}
+	return v
+}
+
+// pacmanRelationKinds .PKGINFO中的关系字段，optdepend为可选依赖，makedepend、checkdepend为构建时依赖
+var pacmanRelationKinds = map[string]string{
+	"depend":      "depends",
+	"optdepend":   "suggests",
+	"makedepend":  "build-depends",
+	"checkdepend": "check-depends",
+	"provides":    "provides",
+	"conflict":    "conflicts",
+	"replaces":    "replaces",
+}
+
+// pacmanOperators 按长度优先匹配
+var pacmanOperators = []string{"<=", ">=", "<", ">", "="}
+
+// pacmanRelation 解析pacman的依赖，如glibc>=2.38、sh、libcurl.so=4-64，optdepend带有": 说明"
+// soname依赖的版本后缀-64、-32表示库的位数
+func pacmanRelation(kind, s string) *model.Relation {
+	if kind == "suggests" {
+		s, _, _ = strings.Cut(s, ": ")
+	}
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+
+	d := &model.Dependency{Name: s}
+	if i := strings.IndexAny(s, "<>="); i != -1 {
+		d.Name = s[:i]
+		rest := s[i:]
+		for _, op := range pacmanOperators {
+			if strings.HasPrefix(rest, op) {
+				d.Operator = op
+				d.Version = rest[len(op):]
+				break
+			}
+		}
+	}
+	if strings.HasSuffix(d.Name, ".so") {
+		d.Namespace = "so"
+		for _, bits := range []string{"64", "32"} {
+			if strings.HasSuffix(d.Version, "-"+bits) {
+				d.Version = strings.TrimSuffix(d.Version, "-"+bits)
+				d.Arch = bits + "bit"
+			}
+		}
+	}
+	return &model.Relation{Kind: kind, Alternatives: []*model.Dependency{d}}
+}
@@ -0,0 +1,605 @@
+package parser
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"get_package_md5/model"
+	"get_package_md5/unarchiver"
+	"get_package_md5/utils"
+	"io"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// maxDBSize 包数据库文件的大小上限，dpkg的status在大型镜像中也只有几MB
+const maxDBSize = 64 << 20
+
+// whiteout OCI层中表示删除的文件名前缀，.wh..wh..opq表示清空下层中的同名目录
+const (
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = ".wh..wh..opq"
+)
+
+// rpmDBFiles rpm的包数据库及其格式，BDB(CentOS 7/8、Amazon Linux 2等)暂不支持解析，只记录其存在
+var rpmDBFiles = map[string]string{
+	"var/lib/rpm/Packages":              "bdb",
+	"var/lib/rpm/Packages.db":           "ndb",
+	"var/lib/rpm/rpmdb.sqlite":          "sqlite",
+	"usr/lib/sysimage/rpm/Packages":     "bdb",
+	"usr/lib/sysimage/rpm/Packages.db":  "ndb",
+	"usr/lib/sysimage/rpm/rpmdb.sqlite": "sqlite",
+	"usr/share/rpm/Packages":            "bdb",
+	"usr/share/rpm/rpmdb.sqlite":        "sqlite",
+}
+
+// usrMerged usrmerge之后/bin等目录为指向/usr下对应目录的链接，包数据库中两种路径都可能出现
+var usrMerged = []string{"bin", "sbin", "lib", "lib32", "lib64", "libx32"}
+
+// RootFS 按层叠加的根文件系统，记录其中ELF文件的摘要以及包数据库的内容，用于分析容器镜像等已安装的系统
+// 同一路径以上层为准，whiteout删除下层中的文件
+type RootFS struct {
+	files map[string]*rootEntry
+	dbs   map[string]*rootEntry
+}
+
+// rootEntry 文件所在的层以及内容，ELF文件只保留摘要，包数据库保留原始内容
+type rootEntry struct {
+	layer int
+	file  *model.File
+	data  []byte
+	// err 包数据库无法读入时的原因(如超过maxDBSize)，记录在Installation.Unsupported中
+	err error
+}
+
+func NewRootFS() *RootFS {
+	return &RootFS{
+		files: map[string]*rootEntry{},
+		dbs:   map[string]*rootEntry{},
+	}
+}
+
+// Add 添加第layer层(从0开始，需按顺序添加)中的文件n，layerID为该层的diff_id，r为unarchiver回调中的reader
+func (fs *RootFS) Add(layer int, layerID, n string, r io.Reader) error {
+	n = strings.TrimPrefix(path.Clean("/"+n), "/")
+	dir, base := path.Split(n)
+	dir = strings.TrimSuffix(dir, "/")
+
+	switch {
+	case base == whiteoutOpaque:
+		fs.remove(dir, layer, true)
+		return nil
+	case strings.HasPrefix(base, whiteoutPrefix):
+		fs.remove(path.Join(dir, strings.TrimPrefix(base, whiteoutPrefix)), layer, false)
+		return nil
+	}
+
+	// 上层的文件覆盖下层的同名文件
+	delete(fs.files, n)
+	delete(fs.dbs, n)
+
+	info := unarchiver.Info(r)
+	if info != nil {
+		if h, ok := info.Sys().(*tar.Header); ok && h.Typeflag == tar.TypeLink {
+			fs.link(layer, n, strings.TrimPrefix(path.Clean("/"+h.Linkname), "/"))
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+	}
+
+	if rpmDBFiles[n] == "bdb" {
+		fs.dbs[n] = &rootEntry{layer: layer}
+		return nil
+	}
+	if isDBFile(n) {
+		// 截断的数据库无法正确解析，超过上限时不读入并记录原因
+		if info != nil && info.Size() > maxDBSize {
+			fs.dbs[n] = &rootEntry{layer: layer, err: errors.Errorf("database too large (%d bytes)", info.Size())}
+			return nil
+		}
+		data, err := io.ReadAll(io.LimitReader(r, maxDBSize+1))
+		if err != nil {
+			return errors.WithMessagef(err, "read %s", n)
+		}
+		if len(data) > maxDBSize {
+			fs.dbs[n] = &rootEntry{layer: layer, err: errors.Errorf("database too large (more than %d bytes)", maxDBSize)}
+			return nil
+		}
+		fs.dbs[n] = &rootEntry{layer: layer, data: data}
+		return nil
+	}
+
+	if utils.NoBinary(n) {
+		return nil
+	}
+	f, err := hashElf(n, r, info)
+	if err != nil {
+		return errors.WithMessagef(err, "calculate hash of %s", n)
+	}
+	if f != nil {
+		f.Layer = layerID
+		fs.files[n] = &rootEntry{layer: layer, file: f}
+	}
+	return nil
+}
+
+// remove 删除下层中的p及其下的文件，children为true时只删除其下的文件(opaque目录)
+func (fs *RootFS) remove(p string, layer int, children bool) {
+	prefix := p + "/"
+	if p == "" || p == "." {
+		prefix = ""
+	}
+	for _, m := range []map[string]*rootEntry{fs.files, fs.dbs} {
+		for n, e := range m {
+			if e.layer >= layer {
+				continue
+			}
+			if (!children && n == p) || strings.HasPrefix(n, prefix) {
+				delete(m, n)
+			}
+		}
+	}
+}
+
+// link 硬链接与目标共享内容，目标需在同一层中先出现
+func (fs *RootFS) link(layer int, n, target string) {
+	if e, ok := fs.files[target]; ok {
+		f := *e.file
+		f.Path = n
+		fs.files[n] = &rootEntry{layer: layer, file: &f}
+	}
+	if e, ok := fs.dbs[target]; ok {
+		fs.dbs[n] = &rootEntry{layer: layer, data: e.data, err: e.err}
+	}
+}
+
+// isDBFile 需要读入内存的包数据库及系统信息文件
+func isDBFile(n string) bool {
+	switch {
+	case rpmDBFiles[n] != "":
+		return true
+	case n == "var/lib/dpkg/status", n == "lib/apk/db/installed",
+		n == "etc/os-release", n == "usr/lib/os-release":
+		return true
+	case strings.HasPrefix(n, "var/lib/dpkg/status.d/"):
+		return true
+	case strings.HasPrefix(n, "var/lib/dpkg/info/") && strings.HasSuffix(n, ".list"):
+		return true
+	case strings.HasPrefix(n, "var/lib/pacman/local/"):
+		base := path.Base(n)
+		return base == "desc" || base == "files"
+	}
+	return isDebCopyright(n)
+}
+
+// Installed 根文件系统中的一个已安装的包，Doc为与对应解析器相同的文档(*model.DebPkg、*model.ApkPkg、*model.PacmanPkg或*model.RpmPkg)
+type Installed struct {
+	Manager string
+	Name    string
+	Version string
+	Arch    string
+	Doc     interface{}
+	// files 包数据库中记录的属于该包的文件，相对根目录
+	files []string
+}
+
+// Installation 根文件系统中识别出的包，Unowned为不属于任何包的ELF文件，Unsupported为无法解析的包数据库
+type Installation struct {
+	OS          string
+	Packages    []*Installed
+	Unowned     []*model.File
+	Unsupported []string
+}
+
+// Packages 解析包数据库并将ELF文件归属到所属的包，meta为各文档共用的来源信息，会复制后挂到每个文档上
+func (fs *RootFS) Packages(meta *model.IndexMeta) *Installation {
+	inst := &Installation{OS: fs.osRelease()}
+	if meta.OS != "" {
+		inst.OS = meta.OS
+	}
+
+	inst.Packages = append(inst.Packages, fs.dpkgPackages()...)
+	inst.Packages = append(inst.Packages, fs.apkPackages()...)
+	inst.Packages = append(inst.Packages, fs.pacmanPackages()...)
+	rpms, unsupported := fs.rpmPackages()
+	inst.Packages = append(inst.Packages, rpms...)
+	inst.Unsupported = unsupported
+	for _, n := range fs.dbNames(func(n string) bool { return fs.dbs[n].err != nil }) {
+		log.Printf("skip %s: %s\n", n, fs.dbs[n].err)
+		inst.Unsupported = append(inst.Unsupported, n)
+	}
+	sort.Strings(inst.Unsupported)
+
+	owners := map[string]*Installed{}
+	for _, p := range inst.Packages {
+		for _, f := range p.files {
+			owners[f] = p
+			if alias := usrMergeAlias(f); alias != "" {
+				if _, ok := owners[alias]; !ok {
+					owners[alias] = p
+				}
+			}
+		}
+	}
+
+	var names []string
+	for n := range fs.files {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		f := fs.files[n].file
+		if p, ok := owners[n]; ok {
+			p.addFile(f)
+			continue
+		}
+		inst.Unowned = append(inst.Unowned, f)
+	}
+
+	for _, p := range inst.Packages {
+		m := *meta
+		m.OS = inst.OS
+		m.Name = p.Name
+		m.Version = p.Version
+		m.Architecture = p.Arch
+		p.setMeta(&m)
+	}
+	return inst
+}
+
+// usrMergeAlias /bin/foo与/usr/bin/foo互为别名
+func usrMergeAlias(n string) string {
+	for _, d := range usrMerged {
+		if strings.HasPrefix(n, d+"/") {
+			return "usr/" + n
+		}
+		if strings.HasPrefix(n, "usr/"+d+"/") {
+			return strings.TrimPrefix(n, "usr/")
+		}
+	}
+	return ""
+}
+
+func (p *Installed) addFile(f *model.File) {
+	switch d := p.Doc.(type) {
+	case *model.DebPkg:
+		if d.Hashes == nil {
+			d.Hashes = map[string]string{}
+		}
+		d.Hashes[f.MD5] = f.Path
+		d.Files = append(d.Files, f)
+	case *model.ApkPkg:
+		d.Hashes = append(d.Hashes, model.Hash{Key: f.MD5, Value: f.Path})
+		d.Files = append(d.Files, f)
+	case *model.PacmanPkg:
+		d.Hashes = append(d.Hashes, model.Hash{Key: f.MD5, Value: f.Path})
+		d.Files = append(d.Files, f)
+	case *model.RpmPkg:
+		d.Hashes = append(d.Hashes, model.Hash{Key: f.MD5, Value: f.Path})
+		d.Files = append(d.Files, f)
+	}
+}
+
+func (p *Installed) setMeta(meta *model.IndexMeta) {
+	switch d := p.Doc.(type) {
+	case *model.DebPkg:
+		d.OS, d.Index = meta.OS, meta
+		attributeLicenses(d)
+	case *model.ApkPkg:
+		d.OS, d.Index = meta.OS, meta
+	case *model.PacmanPkg:
+		d.OS, d.Index = meta.OS, meta
+	case *model.RpmPkg:
+		d.OS, d.Index = meta.OS, meta
+	}
+}
+
+// osRelease 由os-release中的ID与VERSION_ID拼出"debian 12"形式的系统版本
+func (fs *RootFS) osRelease() string {
+	for _, n := range []string{"etc/os-release", "usr/lib/os-release"} {
+		e, ok := fs.dbs[n]
+		if !ok {
+			continue
+		}
+		vals := map[string]string{}
+		_ = readLines(bytes.NewReader(e.data), func(line string) {
+			if k, v, ok := strings.Cut(line, "="); ok {
+				vals[k] = strings.Trim(v, `"'`)
+			}
+		})
+		return strings.TrimSpace(vals["ID"] + " " + vals["VERSION_ID"])
+	}
+	return ""
+}
+
+// dbNames 按名称排序返回满足match的包数据库文件，使输出的包顺序固定
+func (fs *RootFS) dbNames(match func(n string) bool) []string {
+	var names []string
+	for n := range fs.dbs {
+		if match(n) {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// dpkgPackages 解析var/lib/dpkg/status以及distroless镜像使用的status.d
+// 文件清单来自info/<包名>[:架构].list，status.d中为<包名>.md5sums
+func (fs *RootFS) dpkgPackages() []*Installed {
+	var pkgs []*Installed
+	add := func(s *Stanza, lists ...string) {
+		if status := s.Get("Status"); status != "" && !strings.HasSuffix(status, " installed") {
+			return
+		}
+		p := new(model.DebPkg)
+		applyControlFields(s, p)
+		if p.Name == "" {
+			return
+		}
+		inst := &Installed{Manager: "dpkg", Name: p.Name, Version: p.Version, Arch: p.Architecture, Doc: p}
+		for _, l := range lists {
+			e, ok := fs.dbs[l]
+			if !ok {
+				continue
+			}
+			_ = readLines(bytes.NewReader(e.data), func(line string) {
+				// md5sums每行为"<md5>  <路径>"，list每行为一个绝对路径
+				if strings.HasSuffix(l, ".md5sums") {
+					if i := strings.IndexAny(line, " \t"); i != -1 {
+						line = strings.TrimSpace(line[i:])
+					}
+				}
+				inst.files = append(inst.files, strings.TrimPrefix(path.Clean("/"+line), "/"))
+			})
+			break
+		}
+		if e, ok := fs.dbs[path.Join("usr/share/doc", p.Name, "copyright")]; ok && isDep5(e.data) {
+			_ = analyzeCopyright(path.Join("usr/share/doc", p.Name, "copyright"), e.data, p)
+		}
+		pkgs = append(pkgs, inst)
+	}
+
+	if e, ok := fs.dbs["var/lib/dpkg/status"]; ok {
+		_ = ReadStanzas(bytes.NewReader(e.data), func(s *Stanza) bool {
+			name, arch := s.Get("Package"), s.Get("Architecture")
+			add(s, "var/lib/dpkg/info/"+name+".list", "var/lib/dpkg/info/"+name+":"+arch+".list")
+			return true
+		})
+	}
+	for _, n := range fs.dbNames(func(n string) bool {
+		return strings.HasPrefix(n, "var/lib/dpkg/status.d/") && !strings.HasSuffix(n, ".md5sums")
+	}) {
+		_ = ReadStanzas(bytes.NewReader(fs.dbs[n].data), func(s *Stanza) bool {
+			add(s, n+".md5sums", "var/lib/dpkg/status.d/"+s.Get("Package")+".md5sums")
+			return true
+		})
+	}
+	return pkgs
+}
+
+// apkPackages 解析lib/apk/db/installed，记录以空行分隔，每行为"K:value"，F为目录，R为其下的文件
+func (fs *RootFS) apkPackages() []*Installed {
+	e, ok := fs.dbs["lib/apk/db/installed"]
+	if !ok {
+		return nil
+	}
+
+	var pkgs []*Installed
+	p, inst, dir := new(model.ApkPkg), &Installed{Manager: "apk"}, ""
+	flush := func() {
+		if p.PkgName != "" {
+			inst.Doc, inst.Name, inst.Version, inst.Arch = p, p.PkgName, p.PkgVer, p.Arch
+			pkgs = append(pkgs, inst)
+		}
+		p, inst, dir = new(model.ApkPkg), &Installed{Manager: "apk"}, ""
+	}
+
+	sc := bufio.NewScanner(bytes.NewReader(e.data))
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" {
+			flush()
+			continue
+		}
+		if len(line) < 2 || line[1] != ':' {
+			continue
+		}
+		val := line[2:]
+		switch line[0] {
+		case 'P':
+			p.PkgName = val
+		case 'V':
+			p.PkgVer = val
+		case 'A':
+			p.Arch = val
+		case 'T':
+			p.PkgDesc = val
+		case 'U':
+			p.URL = val
+		case 'L':
+			p.License = append(p.License, val)
+		case 'o':
+			p.Origin = val
+		case 'm':
+			p.Maintainer = val
+		case 'I':
+			p.Size = val
+		case 'D':
+			for _, d := range strings.Fields(val) {
+				p.Depend = append(p.Depend, d)
+				if rel := apkRelation("depends", d); rel != nil {
+					p.Relations = append(p.Relations, rel)
+				}
+			}
+		case 'p':
+			for _, d := range strings.Fields(val) {
+				p.Provides = append(p.Provides, d)
+				if rel := apkRelation("provides", d); rel != nil {
+					p.Relations = append(p.Relations, rel)
+				}
+			}
+		case 'F':
+			dir = val
+		case 'R':
+			inst.files = append(inst.files, path.Join(dir, val))
+		}
+	}
+	flush()
+	return pkgs
+}
+
+// pacmanPackages 解析var/lib/pacman/local/<名称-版本>/下的desc与files
+func (fs *RootFS) pacmanPackages() []*Installed {
+	var pkgs []*Installed
+	for _, n := range fs.dbNames(func(n string) bool {
+		return strings.HasPrefix(n, "var/lib/pacman/local/") && path.Base(n) == "desc"
+	}) {
+		p := new(model.PacmanPkg)
+		readPacmanSections(fs.dbs[n].data, func(section, line string) {
+			switch section {
+			case "%NAME%":
+				p.PkgName = line
+			case "%BASE%":
+				p.PkgBase = line
+			case "%VERSION%":
+				p.PkgVer = line
+			case "%DESC%":
+				p.PkgDesc = line
+			case "%URL%":
+				p.URL = line
+			case "%ARCH%":
+				p.Arch = line
+			case "%BUILDDATE%":
+				p.BuildDate = line
+			case "%PACKAGER%":
+				p.Packager = line
+			case "%SIZE%":
+				p.Size = line
+			case "%LICENSE%":
+				p.License = append(p.License, line)
+			case "%GROUPS%":
+				p.Groups = append(p.Groups, line)
+			case "%DEPENDS%":
+				p.Depends = append(p.Depends, line)
+			case "%OPTDEPENDS%":
+				p.OptDepends = append(p.OptDepends, line)
+			case "%PROVIDES%":
+				p.Provides = append(p.Provides, line)
+			case "%CONFLICTS%":
+				p.Conflicts = append(p.Conflicts, line)
+			case "%REPLACES%":
+				p.Replaces = append(p.Replaces, line)
+			}
+			if kind, ok := pacmanLocalKinds[section]; ok {
+				if rel := pacmanRelation(kind, line); rel != nil {
+					p.Relations = append(p.Relations, rel)
+				}
+			}
+		})
+		if p.PkgName == "" {
+			continue
+		}
+
+		inst := &Installed{Manager: "pacman", Name: p.PkgName, Version: p.PkgVer, Arch: p.Arch, Doc: p}
+		if files, ok := fs.dbs[path.Join(path.Dir(n), "files")]; ok {
+			readPacmanSections(files.data, func(section, line string) {
+				if section == "%FILES%" && !strings.HasSuffix(line, "/") {
+					inst.files = append(inst.files, line)
+				}
+			})
+		}
+		pkgs = append(pkgs, inst)
+	}
+	return pkgs
+}
+
+// rpmPackages 解析rpm的包数据库，同时返回无法解析的数据库(BDB或已损坏)
+// 文件清单来自头部中记录的文件，gpg-pubkey为导入的公钥，不是实际的包
+func (fs *RootFS) rpmPackages() ([]*Installed, []string) {
+	names := fs.dbNames(func(n string) bool {
+		return rpmDBFiles[n] != "" && fs.dbs[n].err == nil
+	})
+
+	var pkgs []*Installed
+	var unsupported []string
+	// var/lib/rpm通常是指向usr/lib/sysimage/rpm的链接，同时存在两份数据库时只保留一份
+	seen := map[string]bool{}
+	for _, n := range names {
+		blobs, err := rpmDBHeaders(rpmDBFiles[n], fs.dbs[n].data)
+		if err != nil {
+			if !errors.Is(err, ErrUnsupported) {
+				log.Printf("read rpmdb %s: %s\n", n, err)
+			}
+			unsupported = append(unsupported, n)
+			continue
+		}
+		for _, blob := range blobs {
+			pkg, err := readRpmHeader(blob)
+			if err != nil {
+				log.Printf("read header in %s: %s\n", n, err)
+				continue
+			}
+			if pkg.Name() == "gpg-pubkey" {
+				continue
+			}
+			p := &model.RpmPkg{Manager: "rpm"}
+			assignTo(pkg, p)
+			rpmManifest(pkg, p)
+
+			version := p.Version + "-" + p.Release
+			if p.Epoch != 0 {
+				version = strconv.Itoa(p.Epoch) + ":" + version
+			}
+			key := p.Name + "=" + version + "." + p.Architecture
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			inst := &Installed{Manager: "rpm", Name: p.Name, Version: version, Arch: p.Architecture, Doc: p}
+			for _, f := range p.Manifest {
+				if !strings.HasPrefix(f.Mode, "d") {
+					inst.files = append(inst.files, strings.TrimPrefix(f.Path, "/"))
+				}
+			}
+			pkgs = append(pkgs, inst)
+		}
+	}
+	return pkgs, unsupported
+}
+
+// pacmanLocalKinds 本地数据库desc中的关系段
+var pacmanLocalKinds = map[string]string{
+	"%DEPENDS%":    "depends",
+	"%OPTDEPENDS%": "suggests",
+	"%PROVIDES%":   "provides",
+	"%CONFLICTS%":  "conflicts",
+	"%REPLACES%":   "replaces",
+}
+
+// readPacmanSections 读取%NAME%形式分段的文件，每段的值占之后的若干行，以空行结束
+func readPacmanSections(data []byte, do func(section, line string)) {
+	section := ""
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case line == "":
+			section = ""
+		case strings.HasPrefix(line, "%") && strings.HasSuffix(line, "%"):
+			section = line
+		case section != "":
+			do(section, line)
+		}
+	}
+}
@@ -1,73 +1,196 @@
 package parser
 
 import (
+	"context"
 	"fmt"
 	"get_package_md5/model"
 	"get_package_md5/unarchiver"
 	"get_package_md5/utils"
 	"io"
+	"path"
+	"strconv"
 	"strings"
 
 	"github.com/cavaliergopher/rpm"
 	"github.com/pkg/errors"
 )
 
+// tagFileDigestAlgo 头部中文件摘要算法的tag
+const tagFileDigestAlgo = 5011
+
 type Rpm struct{}
 
 func NewRpmParser() *Rpm {
 	return &Rpm{}
 }
 
-func (r2 *Rpm) Parse(r io.Reader, out string) (err error) {
+func (r2 *Rpm) Parse(ctx context.Context, r io.Reader, meta *model.IndexMeta) (doc interface{}, err error) {
 	defer func() {
 		if e := recover(); e != nil {
 			err = errors.Errorf("panic err %s", e)
 		}
 	}()
 
+	r = unarchiver.WithContext(ctx, r)
 	rpmPkg := new(model.RpmPkg)
-	o, _ := utils.Extract("rpm", out)
+	o, _ := utils.Extract("rpm", meta.Path)
 	if o != "" {
 		rpmPkg.OS = o
 	}
+	if meta.OS != "" {
+		rpmPkg.OS = meta.OS
+	}
+	rpmPkg.Index = meta
 
 	pkg, err := rpm.Read(r)
 	if err != nil {
-		return errors.WithMessagef(err, "read rpm head")
+		return nil, errors.WithMessagef(err, "read rpm head")
 	}
 	assignTo(pkg, rpmPkg)
 
-	if compression := pkg.PayloadCompression(); compression != "xz" {
-		return errors.Errorf("cannot parse such compression of rpm %s", compression)
+	if format := pkg.PayloadFormat(); format != "" && format != "cpio" {
+		return nil, errors.WithMessagef(ErrUnsupported, "rpm payload format %s", format)
 	}
-	if err := unarchiver.ReadCpioXz(r, func(n string, r io.Reader) error {
+	compression := pkg.PayloadCompression()
+	readFunc, ok := payloadReaders[compression]
+	if !ok {
+		return nil, errors.WithMessagef(ErrUnsupported, "rpm payload compression %s", compression)
+	}
+	manifest := rpmManifest(pkg, rpmPkg)
+	if err := readFunc(r, func(n string, r io.Reader) error {
+		// cpio中的路径形如./usr/bin/ls，头部中为/usr/bin/ls
+		entry := manifest[path.Clean("/"+n)]
+		// %license文件直接识别内容，%doc中只识别名称像许可证的文件
+		if entry.HasFlag("license") || entry.HasFlag("doc") && utils.IsLicenseFile(n) {
+			data, err := io.ReadAll(io.LimitReader(r, utils.MaxLicenseSize))
+			if err != nil {
+				return errors.WithMessagef(err, "read %s", n)
+			}
+			if l := utils.ScanLicense(n, data); l != nil {
+				rpmPkg.Licences = append(rpmPkg.Licences, l)
+			}
+			return nil
+		}
 		if utils.NoBinary(n) {
 			return nil
 		}
 
-		data, err := io.ReadAll(r)
+		f, err := hashElf(n, r, unarchiver.Info(r))
 		if err != nil {
-			return errors.WithMessagef(err, "read reader")
-		}
-
-		if ok, md5Val, err := utils.CheckElf(data); ok {
-			rpmPkg.Hashes = append(rpmPkg.Hashes, model.Hash{Key: md5Val, Value: n})
-		} else if err != nil {
 			return errors.WithMessagef(err, "check elf")
 		}
+		if f != nil {
+			rpmPkg.Hashes = append(rpmPkg.Hashes, model.Hash{Key: f.MD5, Value: n})
+			rpmPkg.Files = append(rpmPkg.Files, f)
+			if m := crossCheck(entry, f, rpmPkg.DigestAlgo); m != nil {
+				rpmPkg.Mismatches = append(rpmPkg.Mismatches, m)
+			}
+		}
 
 		return nil
 	}); err != nil {
-		return err
+		return nil, err
 	}
 
-	if err := utils.SaveJson(rpmPkg, out); err != nil {
-		return errors.WithMessagef(err, "save json")
+	return rpmPkg, nil
+}
+
+// rpmDigestAlgos 头部FILEDIGESTALGO的取值，未声明时为md5
+var rpmDigestAlgos = map[int64]string{
+	0:  "md5",
+	1:  "md5",
+	2:  "sha1",
+	8:  "sha256",
+	9:  "sha384",
+	10: "sha512",
+	11: "sha224",
+}
+
+// rpmFileFlags 头部FILEFLAGS中需要记录的标记
+var rpmFileFlags = []struct {
+	flag int64
+	name string
+}{
+	{rpm.FileFlagConfig, "config"},
+	{rpm.FileFlagDoc, "doc"},
+	{rpm.FileFlagMissingOk, "missingok"},
+	{rpm.FileFlagNoReplace, "noreplace"},
+	{rpm.FileFlagGhost, "ghost"},
+	{rpm.FileFlagLicense, "license"},
+	{rpm.FileFlagReadme, "readme"},
+	{rpm.FileFlagArtifact, "artifact"},
+}
+
+// rpmManifest 将头部中的文件清单记录到rpmPkg，返回以路径为key的清单
+func rpmManifest(pkg *rpm.Package, rpmPkg *model.RpmPkg) map[string]*model.RpmFile {
+	rpmPkg.DigestAlgo = rpmDigestAlgos[pkg.Header.GetTag(tagFileDigestAlgo).Int64()]
+	if rpmPkg.DigestAlgo == "" {
+		rpmPkg.DigestAlgo = "unknown"
 	}
 
+	manifest := map[string]*model.RpmFile{}
+	for _, fi := range pkg.Files() {
+		f := &model.RpmFile{
+			Path:     fi.Name(),
+			Size:     fi.Size(),
+			Mode:     fi.Mode().String(),
+			Digest:   fi.Digest(),
+			Owner:    fi.Owner(),
+			Group:    fi.Group(),
+			Linkname: fi.Linkname(),
+		}
+		for _, ff := range rpmFileFlags {
+			if fi.Flags()&ff.flag != 0 {
+				f.Flags = append(f.Flags, ff.name)
+			}
+		}
+		rpmPkg.Manifest = append(rpmPkg.Manifest, f)
+		manifest[f.Path] = f
+	}
+	return manifest
+}
+
+// crossCheck 比对计算出的摘要、大小与头部中记录的值，不一致时返回差异
+func crossCheck(entry *model.RpmFile, f *model.File, algo string) *model.DigestMismatch {
+	if entry == nil {
+		return &model.DigestMismatch{Path: f.Path, Reason: "not in header manifest"}
+	}
+	if entry.Size != f.Size {
+		return &model.DigestMismatch{
+			Path:     f.Path,
+			Reason:   "size",
+			Expected: strconv.FormatInt(entry.Size, 10),
+			Actual:   strconv.FormatInt(f.Size, 10),
+		}
+	}
+	var actual string
+	switch algo {
+	case "md5":
+		actual = f.MD5
+	case "sha1":
+		actual = f.SHA1
+	case "sha256":
+		actual = f.SHA256
+	default:
+		// 未计算该算法的摘要，无法比对
+		return nil
+	}
+	if entry.Digest != "" && !strings.EqualFold(entry.Digest, actual) {
+		return &model.DigestMismatch{Path: f.Path, Reason: algo, Expected: entry.Digest, Actual: actual}
+	}
 	return nil
 }
 
+// payloadReaders 按PayloadCompression选择payload的解压方式，未声明时为gzip
+var payloadReaders = map[string]unarchiver.ReadFunc{
+	"":      unarchiver.ReadCpioGzip,
+	"gzip":  unarchiver.ReadCpioGzip,
+	"bzip2": unarchiver.ReadCpioBz2,
+	"xz":    unarchiver.ReadCpioXz,
+	"lzma":  unarchiver.ReadCpioLzma,
+	"zstd":  unarchiver.ReadCpioZst,
+}
+
 func (r2 *Rpm) Check(n string) bool {
 	return strings.HasSuffix(n, ".rpm")
 }
@@ -85,6 +208,14 @@ func assignTo(p *rpm.Package, pp *model.RpmPkg) {
 		deb := fmt.Sprintf("%s;%s;%s;%d", d.Name(), d.Version(), d.Release(), d.Epoch())
 		pp.Depends = append(pp.Depends, deb)
 	}
+	pp.Relations = append(pp.Relations, rpmRelations("depends", p.Requires())...)
+	pp.Relations = append(pp.Relations, rpmRelations("provides", p.Provides())...)
+	pp.Relations = append(pp.Relations, rpmRelations("conflicts", p.Conflicts())...)
+	pp.Relations = append(pp.Relations, rpmRelations("obsoletes", p.Obsoletes())...)
+	pp.Relations = append(pp.Relations, rpmRelations("recommends", p.Recommends())...)
+	pp.Relations = append(pp.Relations, rpmRelations("suggests", p.Suggests())...)
+	pp.Relations = append(pp.Relations, rpmRelations("supplements", p.Supplements())...)
+	pp.Relations = append(pp.Relations, rpmRelations("enhances", p.Enhances())...)
 	pp.Architecture = p.Architecture()
 	pp.Description = p.Description()
 	pp.License = append(pp.License, p.License())
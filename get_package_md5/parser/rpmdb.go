@@ -0,0 +1,98 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/cavaliergopher/rpm"
+	"github.com/pkg/errors"
+)
+
+// ndb(SUSE等使用的rpm原生格式)中的魔数，文件均为小端
+const (
+	ndbHeaderMagic = 'R' | 'p'<<8 | 'm'<<16 | 'P'<<24
+	ndbSlotMagic   = 'S' | 'l'<<8 | 'o'<<16 | 't'<<24
+	ndbBlobMagic   = 'B' | 'l'<<8 | 'b'<<16 | 'S'<<24
+)
+
+// ndb文件开头为若干页的slot，每个slot记录一个包的头部所在的块，块大小为16字节
+const (
+	ndbPageSize   = 4096
+	ndbHeaderSize = 32
+	ndbSlotSize   = 16
+	ndbBlockSize  = 16
+)
+
+// rpmDBHeaders 读取rpm包数据库中每个已安装包的头部，format为rpmDBFiles中的格式，BDB暂不支持
+func rpmDBHeaders(format string, data []byte) ([][]byte, error) {
+	switch format {
+	case "sqlite":
+		// rpm 4.16起的默认格式，表结构为Packages(hnum INTEGER PRIMARY KEY, blob BLOB)
+		var blobs [][]byte
+		err := readSqliteTable(data, "Packages", func(_ int64, values []interface{}) error {
+			if len(values) > 1 {
+				if b, ok := values[1].([]byte); ok {
+					blobs = append(blobs, b)
+				}
+			}
+			return nil
+		})
+		return blobs, err
+	case "ndb":
+		return ndbBlobs(data)
+	}
+	return nil, errors.WithMessagef(ErrUnsupported, "%s rpmdb", format)
+}
+
+// ndbBlobs 读取ndb的Packages.db，文件头之后为slot，pkgidx为0的slot未使用
+// 每个头部之前有16字节的blob头(magic、pkgidx、generation、长度)
+func ndbBlobs(data []byte) ([][]byte, error) {
+	le := binary.LittleEndian
+	if len(data) < ndbHeaderSize || le.Uint32(data) != ndbHeaderMagic {
+		return nil, errors.New("invalid ndb magic")
+	}
+	if v := le.Uint32(data[4:]); v != 0 {
+		return nil, errors.Errorf("unsupported ndb version %d", v)
+	}
+	slotEnd := int64(le.Uint32(data[12:])) * ndbPageSize
+	if slotEnd > int64(len(data)) {
+		return nil, errors.Errorf("ndb slot pages out of range")
+	}
+
+	var blobs [][]byte
+	// 文件头占用了前两个slot的位置
+	for off := int64(ndbHeaderSize); off+ndbSlotSize <= slotEnd; off += ndbSlotSize {
+		slot := data[off : off+ndbSlotSize]
+		if le.Uint32(slot) != ndbSlotMagic {
+			return nil, errors.Errorf("invalid ndb slot at %d", off)
+		}
+		idx := le.Uint32(slot[4:])
+		if idx == 0 {
+			continue
+		}
+		blk := int64(le.Uint32(slot[8:])) * ndbBlockSize
+		if blk+ndbBlockSize > int64(len(data)) {
+			return nil, errors.Errorf("blob of package %d out of range", idx)
+		}
+		h := data[blk : blk+ndbBlockSize]
+		if le.Uint32(h) != ndbBlobMagic || le.Uint32(h[4:]) != idx {
+			return nil, errors.Errorf("invalid blob of package %d", idx)
+		}
+		end := blk + ndbBlockSize + int64(le.Uint32(h[12:]))
+		if end > int64(len(data)) {
+			return nil, errors.Errorf("blob of package %d out of range", idx)
+		}
+		blobs = append(blobs, data[blk+ndbBlockSize:end])
+	}
+	return blobs, nil
+}
+
+// readRpmHeader 解析数据库中的头部，数据库中只保存头部的索引与数据，补上lead、空的签名头部以及头部的magic后交给rpm.Read
+func readRpmHeader(blob []byte) (*rpm.Package, error) {
+	buf := make([]byte, 96, 96+16+8+len(blob))
+	copy(buf, []byte{0xed, 0xab, 0xee, 0xdb, 3, 0})
+	buf = append(buf, 0x8e, 0xad, 0xe8, 0x01, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0)
+	buf = append(buf, 0x8e, 0xad, 0xe8, 0x01, 0, 0, 0, 0)
+	buf = append(buf, blob...)
+	return rpm.Read(bytes.NewReader(buf))
+}
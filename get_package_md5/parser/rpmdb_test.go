@@ -0,0 +1,146 @@
+package parser
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/binary"
+	"get_package_md5/model"
+	"get_package_md5/unarchiver"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// testdata/rpmdb.sqlite由sqlite3按rpm的表结构生成，包含epel-release与centos-release两个包的头部
+func readRpmDBTestdata(t *testing.T) [][]byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "rpmdb.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	blobs, err := rpmDBHeaders("sqlite", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return blobs
+}
+
+func rpmNames(t *testing.T, blobs [][]byte) []string {
+	t.Helper()
+	var names []string
+	for _, b := range blobs {
+		pkg, err := readRpmHeader(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, pkg.Name()+"-"+pkg.Version()+"-"+pkg.Release()+"."+pkg.Architecture())
+	}
+	return names
+}
+
+func TestRpmDBSqlite(t *testing.T) {
+	got := rpmNames(t, readRpmDBTestdata(t))
+	want := []string{"epel-release-7-5.noarch", "centos-release-7-2.1511.el7.centos.2.10.x86_64"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// writeNdb 按ndb的格式写入blobs，第一个slot留空
+func writeNdb(blobs [][]byte) []byte {
+	le := binary.LittleEndian
+	data := make([]byte, ndbPageSize)
+	le.PutUint32(data, ndbHeaderMagic)
+	le.PutUint32(data[12:], 1)
+	for off := ndbHeaderSize; off < ndbPageSize; off += ndbSlotSize {
+		le.PutUint32(data[off:], ndbSlotMagic)
+	}
+	for i, b := range blobs {
+		slot := data[ndbHeaderSize+(i+1)*ndbSlotSize:]
+		le.PutUint32(slot[4:], uint32(i+1))
+		le.PutUint32(slot[8:], uint32(len(data)/ndbBlockSize))
+
+		var h [ndbBlockSize]byte
+		le.PutUint32(h[:], ndbBlobMagic)
+		le.PutUint32(h[4:], uint32(i+1))
+		le.PutUint32(h[12:], uint32(len(b)))
+		data = append(data, h[:]...)
+		data = append(data, b...)
+		// blob之后为校验值与结束标记，按块对齐
+		data = append(data, make([]byte, 2*ndbBlockSize-len(b)%ndbBlockSize)...)
+	}
+	return data
+}
+
+func TestRpmDBNdb(t *testing.T) {
+	want := readRpmDBTestdata(t)
+	got, err := rpmDBHeaders("ndb", writeNdb(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %d headers, want %d", len(got), len(want))
+	}
+
+	if _, err := rpmDBHeaders("ndb", []byte("RpmP")); err == nil {
+		t.Error("truncated ndb: want error")
+	}
+}
+
+func TestRootFSRpm(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "rpmdb.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	elf := append([]byte("\x7fELF\x02\x01\x01"), make([]byte, 120)...)
+
+	fs := NewRootFS()
+	for n, content := range map[string][]byte{
+		"usr/lib/sysimage/rpm/rpmdb.sqlite":   data,
+		"var/lib/rpm/Packages":                []byte("berkeley db"),
+		"etc/pki/rpm-gpg/RPM-GPG-KEY-EPEL-7":  elf,
+		"usr/local/bin/unowned":               elf,
+		"usr/lib/sysimage/rpm/rpmdb.sqlite-x": elf,
+	} {
+		if err := fs.Add(0, "sha256:layer", n, bytes.NewReader(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	inst := fs.Packages(&model.IndexMeta{})
+
+	var got []string
+	for _, p := range inst.Packages {
+		got = append(got, p.Manager+":"+p.Name+"="+p.Version)
+	}
+	want := []string{"rpm:epel-release=7-5", "rpm:centos-release=7-2.1511.el7.centos.2.10"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("packages = %q, want %q", got, want)
+	}
+	epel := inst.Packages[0].Doc.(*model.RpmPkg)
+	if len(epel.Files) != 1 || epel.Files[0].Path != "etc/pki/rpm-gpg/RPM-GPG-KEY-EPEL-7" {
+		t.Errorf("epel-release files = %v", epel.Files)
+	}
+	if len(inst.Unowned) != 2 {
+		t.Errorf("got %d unowned files, want 2", len(inst.Unowned))
+	}
+	if want := []string{"var/lib/rpm/Packages"}; !reflect.DeepEqual(inst.Unsupported, want) {
+		t.Errorf("unsupported = %q, want %q", inst.Unsupported, want)
+	}
+}
+
+func TestRootFSLargeDB(t *testing.T) {
+	fs := NewRootFS()
+	// 超过上限的数据库不读入，不应被截断后解析
+	h := &tar.Header{Name: "var/lib/rpm/rpmdb.sqlite", Typeflag: tar.TypeReg, Mode: 0644, Size: maxDBSize + 1}
+	if err := fs.Add(0, "sha256:layer", h.Name, &unarchiver.Entry{Reader: bytes.NewReader(nil), Info: h.FileInfo()}); err != nil {
+		t.Fatal(err)
+	}
+	inst := fs.Packages(&model.IndexMeta{})
+	if len(inst.Packages) != 0 {
+		t.Errorf("got %d packages, want 0", len(inst.Packages))
+	}
+	if want := []string{h.Name}; !reflect.DeepEqual(inst.Unsupported, want) {
+		t.Errorf("unsupported = %q, want %q", inst.Unsupported, want)
+	}
+}
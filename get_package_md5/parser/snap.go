@@ -0,0 +1,142 @@
+package parser
+
+import (
+	"context"
+	"get_package_md5/model"
+	"get_package_md5/unarchiver"
+	"get_package_md5/utils"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+type Snap struct{}
+
+func NewSnapParser() *Snap {
+	return &Snap{}
+}
+
+// snapYaml meta/snap.yaml中使用的字段，plugs、slots的值可以是接口名或属性表，只取其键
+type snapYaml struct {
+	Name          string                 `yaml:"name"`
+	Version       string                 `yaml:"version"`
+	Title         string                 `yaml:"title"`
+	Summary       string                 `yaml:"summary"`
+	Description   string                 `yaml:"description"`
+	Type          string                 `yaml:"type"`
+	Base          string                 `yaml:"base"`
+	Grade         string                 `yaml:"grade"`
+	Confinement   string                 `yaml:"confinement"`
+	License       string                 `yaml:"license"`
+	Architectures []string               `yaml:"architectures"`
+	Plugs         map[string]interface{} `yaml:"plugs"`
+	Slots         map[string]interface{} `yaml:"slots"`
+	Apps          map[string]struct {
+		Plugs []string `yaml:"plugs"`
+		Slots []string `yaml:"slots"`
+	} `yaml:"apps"`
+}
+
+// snapManifest snapcraft生成的snap/manifest.yaml，primed-stage-packages形如libfoo1=1.2-3
+type snapManifest struct {
+	StagePackages []string `yaml:"primed-stage-packages"`
+}
+
+func (p *Snap) Parse(ctx context.Context, r io.Reader, meta *model.IndexMeta) (doc interface{}, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = errors.Errorf("panic err %s", e)
+		}
+	}()
+
+	// squashfs需要随机访问，先缓存整个包
+	ra, _, closer, err := utils.SpoolReader(unarchiver.WithContext(ctx, r))
+	if err != nil {
+		return nil, errors.WithMessagef(err, "read snap")
+	}
+	defer closer.Close()
+
+	pkg := &model.SnapPkg{Manager: "snap"}
+	found := false
+	if err := readSquashfs(ctx, ra, func(n string, r io.Reader) error {
+		switch n {
+		case "meta/snap.yaml":
+			found = true
+			return errors.WithMessagef(parseSnapYaml(r, pkg), "parse snap.yaml")
+		case "snap/manifest.yaml":
+			return errors.WithMessagef(parseSnapManifest(r, pkg), "parse manifest.yaml")
+		}
+		return hashBundled(n, r, &pkg.Hashes, &pkg.Files)
+	}); err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, errors.New("meta/snap.yaml not found")
+	}
+	pkg.Index = meta
+	return pkg, nil
+}
+
+func (p *Snap) Check(n string) bool {
+	return strings.HasSuffix(n, ".snap")
+}
+
+func parseSnapYaml(r io.Reader, pkg *model.SnapPkg) error {
+	var y snapYaml
+	if err := yaml.NewDecoder(r).Decode(&y); err != nil {
+		return err
+	}
+	pkg.Name = y.Name
+	pkg.Version = y.Version
+	pkg.Title = y.Title
+	pkg.Summary = y.Summary
+	pkg.Description = y.Description
+	pkg.Type = y.Type
+	pkg.Base = y.Base
+	pkg.Grade = y.Grade
+	pkg.Confinement = y.Confinement
+	pkg.License = y.License
+	pkg.Architectures = y.Architectures
+
+	plugs, slots := map[string]bool{}, map[string]bool{}
+	for name := range y.Plugs {
+		plugs[name] = true
+	}
+	for name := range y.Slots {
+		slots[name] = true
+	}
+	for name, app := range y.Apps {
+		pkg.Apps = append(pkg.Apps, name)
+		for _, plug := range app.Plugs {
+			plugs[plug] = true
+		}
+		for _, slot := range app.Slots {
+			slots[slot] = true
+		}
+	}
+	sort.Strings(pkg.Apps)
+	pkg.Plugs = sortedKeys(plugs)
+	pkg.Slots = sortedKeys(slots)
+	return nil
+}
+
+func parseSnapManifest(r io.Reader, pkg *model.SnapPkg) error {
+	var m snapManifest
+	if err := yaml.NewDecoder(r).Decode(&m); err != nil && err != io.EOF {
+		return err
+	}
+	pkg.StagePackages = m.StagePackages
+	return nil
+}
+
+func sortedKeys(m map[string]bool) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
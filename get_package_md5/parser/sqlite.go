@@ -0,0 +1,207 @@
+package parser
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// sqliteMagic sqlite数据库文件开头的16字节
+const sqliteMagic = "SQLite format 3\x00"
+
+var errSqliteCorrupt = errors.New("corrupt sqlite database")
+
+// sqliteDB 只读地解析整个读入内存的sqlite数据库文件，只支持遍历rowid表
+// 未合并到主文件的-wal中的修改不可见
+type sqliteDB struct {
+	data     []byte
+	pageSize int
+	// usable 每页去掉末尾保留区域后可用的大小
+	usable int
+	// visited 已遍历的b-tree页，损坏的文件中页之间可能形成环
+	visited map[uint32]bool
+}
+
+// readSqliteTable 读取表name的所有行，每列的值为nil、int64、float64、string或[]byte
+// INTEGER PRIMARY KEY的列在记录中为nil，其值为rowid
+func readSqliteTable(data []byte, name string, do func(rowid int64, values []interface{}) error) (err error) {
+	defer func() {
+		// 页内的偏移越界说明文件已损坏
+		if e := recover(); e != nil {
+			err = errSqliteCorrupt
+		}
+	}()
+
+	if len(data) < 100 || string(data[:16]) != sqliteMagic {
+		return errors.New("not a sqlite database")
+	}
+	db := &sqliteDB{data: data, pageSize: int(binary.BigEndian.Uint16(data[16:18])), visited: map[uint32]bool{}}
+	if db.pageSize == 1 {
+		db.pageSize = 65536
+	}
+	if db.pageSize < 512 || db.pageSize&(db.pageSize-1) != 0 {
+		return errors.Errorf("invalid sqlite page size %d", db.pageSize)
+	}
+	db.usable = db.pageSize - int(data[20])
+
+	// 第1页为sqlite_master，列为type、name、tbl_name、rootpage、sql
+	root := int64(0)
+	if err := db.walk(1, func(_ int64, values []interface{}) error {
+		if len(values) >= 4 && values[0] == "table" && values[1] == name {
+			root, _ = values[3].(int64)
+		}
+		return nil
+	}); err != nil {
+		return errors.WithMessagef(err, "read sqlite_master")
+	}
+	if root <= 0 || root > math.MaxUint32 {
+		return errors.Errorf("table %s not found", name)
+	}
+	return db.walk(uint32(root), do)
+}
+
+func (db *sqliteDB) page(n uint32) ([]byte, error) {
+	off := (int64(n) - 1) * int64(db.pageSize)
+	if n == 0 || off+int64(db.pageSize) > int64(len(db.data)) {
+		return nil, errors.Errorf("sqlite page %d out of range", n)
+	}
+	return db.data[off : off+int64(db.pageSize)], nil
+}
+
+// walk 按rowid顺序遍历以第n页为根的表b-tree
+func (db *sqliteDB) walk(n uint32, do func(rowid int64, values []interface{}) error) error {
+	if db.visited[n] {
+		return errSqliteCorrupt
+	}
+	db.visited[n] = true
+	p, err := db.page(n)
+	if err != nil {
+		return err
+	}
+	// 第1页开头为100字节的文件头
+	h := 0
+	if n == 1 {
+		h = 100
+	}
+	cells := int(binary.BigEndian.Uint16(p[h+3:]))
+
+	switch p[h] {
+	case 0x05:
+		// 内部页，每个单元为左子树的页号及rowid，最右侧的子树记录在页头中
+		for i := 0; i < cells; i++ {
+			off := binary.BigEndian.Uint16(p[h+12+2*i:])
+			if err := db.walk(binary.BigEndian.Uint32(p[off:]), do); err != nil {
+				return err
+			}
+		}
+		return db.walk(binary.BigEndian.Uint32(p[h+8:]), do)
+	case 0x0d:
+		for i := 0; i < cells; i++ {
+			off := int(binary.BigEndian.Uint16(p[h+8+2*i:]))
+			size, k := sqliteVarint(p[off:])
+			off += k
+			rowid, k := sqliteVarint(p[off:])
+			off += k
+			payload, err := db.payload(p[off:], size)
+			if err != nil {
+				return err
+			}
+			values, err := sqliteRecord(payload)
+			if err != nil {
+				return err
+			}
+			if err := do(rowid, values); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return errors.Errorf("unexpected sqlite page type %#x at page %d", p[h], n)
+}
+
+// payload 读取叶子页中的一个单元，超出页内可存放大小的部分保存在溢出页链表中
+func (db *sqliteDB) payload(cell []byte, size int64) ([]byte, error) {
+	if size < 0 || size > int64(len(db.data)) {
+		return nil, errSqliteCorrupt
+	}
+	u := int64(db.usable)
+	if size <= u-35 {
+		return cell[:size], nil
+	}
+	m := (u-12)*32/255 - 23
+	local := m + (size-m)%(u-4)
+	if local > u-35 {
+		local = m
+	}
+
+	buf := make([]byte, 0, size)
+	buf = append(buf, cell[:local]...)
+	next := binary.BigEndian.Uint32(cell[local:])
+	for int64(len(buf)) < size {
+		p, err := db.page(next)
+		if err != nil {
+			return nil, err
+		}
+		// 溢出页开头4字节为下一页的页号
+		n := min(size-int64(len(buf)), u-4)
+		buf = append(buf, p[4:4+n]...)
+		next = binary.BigEndian.Uint32(p)
+	}
+	return buf, nil
+}
+
+// sqliteRecord 解析记录格式，头部为各列的serial type
+func sqliteRecord(payload []byte) ([]interface{}, error) {
+	hlen, pos := sqliteVarint(payload)
+	if hlen < int64(pos) || hlen > int64(len(payload)) {
+		return nil, errSqliteCorrupt
+	}
+	body := payload[hlen:]
+	var values []interface{}
+	for int64(pos) < hlen {
+		t, k := sqliteVarint(payload[pos:])
+		pos += k
+
+		var v interface{}
+		switch {
+		case t == 0:
+		case t >= 1 && t <= 6:
+			n := [...]int{1, 2, 3, 4, 6, 8}[t-1]
+			// 大端的有符号整数
+			x := int64(int8(body[0]))
+			for _, b := range body[1:n] {
+				x = x<<8 | int64(b)
+			}
+			v, body = x, body[n:]
+		case t == 7:
+			v, body = math.Float64frombits(binary.BigEndian.Uint64(body)), body[8:]
+		case t == 8, t == 9:
+			v = t - 8
+		case t >= 12:
+			n := (t - 12) / 2
+			if t%2 == 0 {
+				v = body[:n]
+			} else {
+				v = string(body[:n])
+			}
+			body = body[n:]
+		default:
+			return nil, errSqliteCorrupt
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// sqliteVarint 大端的变长整数，前8个字节各使用低7位，第9个字节使用全部8位
+func sqliteVarint(b []byte) (int64, int) {
+	var v uint64
+	for i := 0; i < 8; i++ {
+		v = v<<7 | uint64(b[i]&0x7f)
+		if b[i]&0x80 == 0 {
+			return int64(v), i + 1
+		}
+	}
+	return int64(v<<8 | uint64(b[8])), 9
+}
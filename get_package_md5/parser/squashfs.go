@@ -0,0 +1,42 @@
+package parser
+
+import (
+	"context"
+	"get_package_md5/model"
+	"get_package_md5/unarchiver"
+	"get_package_md5/utils"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// readSquashfs 遍历snap、AppImage中的squashfs，ctx取消后停止，不支持的压缩方式视为ErrUnsupported
+func readSquashfs(ctx context.Context, r io.ReaderAt, do func(n string, r io.Reader) error) error {
+	err := unarchiver.ReadSquashfs(r, func(n string, r io.Reader) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return do(n, r)
+	})
+	if errors.Is(err, unarchiver.ErrSquashfsCompression) {
+		return errors.WithMessagef(ErrUnsupported, "%s", err)
+	}
+	return err
+}
+
+// hashBundled 计算snap、AppImage中自带的ELF文件的摘要，不是ELF时返回nil
+func hashBundled(n string, r io.Reader, hashes *[]model.Hash, files *[]*model.File) error {
+	if utils.NoBinary(n) {
+		return nil
+	}
+	f, err := hashElf(n, r, unarchiver.Info(r))
+	if err != nil {
+		return errors.WithMessagef(err, "calculate hash of %s", n)
+	}
+	if f == nil {
+		return nil
+	}
+	*hashes = append(*hashes, model.Hash{Key: f.MD5, Value: n})
+	*files = append(*files, f)
+	return nil
+}
@@ -1,9 +1,8 @@
 package main
 
 import (
-	"crypto/md5"
-	"encoding/hex"
 	"fmt"
+	"get_package_md5/utils"
 	"io"
 	"log"
 	"os"
@@ -13,8 +12,6 @@ import (
 	"github.com/ulikunitz/xz"
 
 	"github.com/cavaliergopher/rpm"
-	"github.com/h2non/filetype"
-	errors2 "github.com/pkg/errors"
 )
 
 func main() {
@@ -88,37 +85,11 @@ func ExtractRPM(name string) {
 		//if utils.NoBinary(hdr.Name) {
 		//	continue
 		//}
-		if ok, m, err := check(cpioReader); ok {
-			fmt.Printf("file:%s, md5:%s\n", hdr.Name, m)
+		if f, err := utils.HashElf(cpioReader); f != nil {
+			fmt.Printf("file:%s, md5:%s, sha256:%s\n", hdr.Name, f.MD5, f.SHA256)
 		} else if err != nil {
 			fmt.Println(err)
 		}
 	}
 	fmt.Println("cost time", time.Since(start).String())
 }
-
-func check(r io.Reader) (bool, string, error) {
-	buffer := make([]byte, 261)
-	_, err := r.Read(buffer)
-	if err != nil && err != io.EOF {
-		return false, "", errors2.Wrapf(err, "read buffer")
-	}
-	t, err := filetype.Get(buffer)
-	if err != nil {
-		return false, "", errors2.Wrapf(err, "get file type")
-	}
-	if t.Extension == "elf" {
-		rest, err := io.ReadAll(r)
-		if err != nil {
-			return false, "", errors2.Wrapf(err, "read all")
-		}
-		return true, calculateMd5(append(buffer, rest...)), nil // ELF 二进制文件
-	}
-	return false, "", nil
-}
-
-func calculateMd5(b []byte) string {
-	hashValue := md5.Sum(b)
-	md5Str := hex.EncodeToString(hashValue[:])
-	return md5Str
-}
@@ -0,0 +1,263 @@
+// Package tlsh 纯go实现的TLSH局部敏感哈希(128桶、1字节校验和，输出带"T1"前缀)
+// 用于识别重新编译、strip或打过补丁的二进制与原始文件的相似程度
+package tlsh
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	windowSize = 5
+	buckets    = 128
+	codeSize   = buckets / 4
+	// MinSize 计算TLSH需要的最少字节数
+	MinSize = 50
+	// hashSize checksum、lvalue、q比值以及32字节的code
+	hashSize = 3 + codeSize
+	bandSize = 2
+)
+
+// ErrInvalid 数据过短或分布过于单一，无法得到有效的TLSH
+var ErrInvalid = errors.New("tlsh: not enough variation in data")
+
+// Hash 可流式写入的TLSH计算器
+type Hash struct {
+	bucket   [256]uint32
+	window   [windowSize]byte
+	checksum byte
+	n        int64
+}
+
+// New 返回新的TLSH计算器
+func New() *Hash {
+	return &Hash{}
+}
+
+func (h *Hash) Write(p []byte) (int, error) {
+	w := &h.window
+	for _, b := range p {
+		j := int(h.n % windowSize)
+		w[j] = b
+		if h.n >= windowSize-1 {
+			j1 := (j + windowSize - 1) % windowSize
+			j2 := (j + windowSize - 2) % windowSize
+			j3 := (j + windowSize - 3) % windowSize
+			j4 := (j + windowSize - 4) % windowSize
+
+			h.checksum = pearson(0, w[j], w[j1], h.checksum)
+			h.bucket[pearson(2, w[j], w[j1], w[j2])]++
+			h.bucket[pearson(3, w[j], w[j1], w[j3])]++
+			h.bucket[pearson(5, w[j], w[j2], w[j3])]++
+			h.bucket[pearson(7, w[j], w[j2], w[j4])]++
+			h.bucket[pearson(11, w[j], w[j1], w[j4])]++
+			h.bucket[pearson(13, w[j], w[j3], w[j4])]++
+		}
+		h.n++
+	}
+	return len(p), nil
+}
+
+// Sum 返回"T1"开头的十六进制TLSH
+func (h *Hash) Sum() (string, error) {
+	if h.n < MinSize {
+		return "", ErrInvalid
+	}
+
+	sorted := make([]uint32, buckets)
+	copy(sorted, h.bucket[:buckets])
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	q1, q2, q3 := sorted[buckets/4-1], sorted[buckets/2-1], sorted[buckets*3/4-1]
+	if q3 == 0 {
+		return "", ErrInvalid
+	}
+
+	nonzero := 0
+	for _, c := range h.bucket[:buckets] {
+		if c > 0 {
+			nonzero++
+		}
+	}
+	if nonzero <= buckets/2 {
+		return "", ErrInvalid
+	}
+
+	var d digest
+	d.checksum = h.checksum
+	d.lvalue = lCapturing(h.n)
+	d.q1 = byte(uint64(q1) * 100 / uint64(q3) % 16)
+	d.q2 = byte(uint64(q2) * 100 / uint64(q3) % 16)
+	for i := 0; i < codeSize; i++ {
+		var c byte
+		for j := 0; j < 4; j++ {
+			k := h.bucket[4*i+j]
+			switch {
+			case q3 < k:
+				c += 3 << (j * 2)
+			case q2 < k:
+				c += 2 << (j * 2)
+			case q1 < k:
+				c += 1 << (j * 2)
+			}
+		}
+		// code按桶的逆序存放
+		d.code[codeSize-1-i] = c
+	}
+	return d.String(), nil
+}
+
+// digest 解析后的TLSH
+type digest struct {
+	checksum byte
+	lvalue   byte
+	q1       byte
+	q2       byte
+	code     [codeSize]byte
+}
+
+func (d *digest) String() string {
+	b := make([]byte, 0, hashSize)
+	b = append(b, swap(d.checksum), swap(d.lvalue), d.q2|d.q1<<4)
+	b = append(b, d.code[:]...)
+	return "T1" + strings.ToUpper(hex.EncodeToString(b))
+}
+
+func parse(s string) (*digest, error) {
+	s = strings.TrimPrefix(strings.ToUpper(s), "T1")
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != hashSize {
+		return nil, errors.Errorf("tlsh: invalid digest %s", s)
+	}
+	d := &digest{
+		checksum: swap(b[0]),
+		lvalue:   swap(b[1]),
+		q1:       b[2] >> 4,
+		q2:       b[2] & 0x0f,
+	}
+	copy(d.code[:], b[3:])
+	return d, nil
+}
+
+// Diff 计算两个TLSH之间的距离(包含长度差异)，0表示几乎相同，通常100以内可认为相似
+func Diff(a, b string) (int, error) {
+	x, err := parse(a)
+	if err != nil {
+		return 0, err
+	}
+	y, err := parse(b)
+	if err != nil {
+		return 0, err
+	}
+
+	diff := 0
+	switch ld := modDiff(int(x.lvalue), int(y.lvalue), 256); ld {
+	case 0:
+	case 1:
+		diff = 1
+	default:
+		diff += ld * 12
+	}
+	for _, qd := range []int{modDiff(int(x.q1), int(y.q1), 16), modDiff(int(x.q2), int(y.q2), 16)} {
+		if qd <= 1 {
+			diff += qd
+		} else {
+			diff += (qd - 1) * 12
+		}
+	}
+	if x.checksum != y.checksum {
+		diff++
+	}
+	for i := range x.code {
+		for j := 0; j < 4; j++ {
+			d := int(x.code[i]>>(j*2)&3) - int(y.code[i]>>(j*2)&3)
+			if d < 0 {
+				d = -d
+			}
+			if d == 3 {
+				d = 6
+			}
+			diff += d
+		}
+	}
+	return diff, nil
+}
+
+// Bands 将TLSH的code按2字节切分为16段，用于在es中检索可能相似的候选文件
+// 距离较小的两个TLSH通常有若干段完全相同，相同的段越多越相似
+func Bands(s string) []string {
+	d, err := parse(s)
+	if err != nil {
+		return nil
+	}
+	bands := make([]string, 0, codeSize/bandSize)
+	for i := 0; i < codeSize; i += bandSize {
+		bands = append(bands, fmt.Sprintf("%d:%X", i/bandSize, d.code[i:i+bandSize]))
+	}
+	return bands
+}
+
+func modDiff(x, y, r int) int {
+	var dl, dr int
+	if y > x {
+		dl = y - x
+		dr = x + r - y
+	} else {
+		dl = x - y
+		dr = y + r - x
+	}
+	if dl > dr {
+		return dr
+	}
+	return dl
+}
+
+// lCapturing 将数据长度映射为一个字节的对数刻度
+func lCapturing(n int64) byte {
+	l := float64(n)
+	var i int
+	switch {
+	case n <= 656:
+		i = int(math.Floor(math.Log(l) / math.Log(1.5)))
+	case n <= 3199:
+		i = int(math.Floor(math.Log(l)/math.Log(1.3) - 8.72777))
+	default:
+		i = int(math.Floor(math.Log(l)/math.Log(1.1) - 62.5472))
+	}
+	return byte(i & 0xff)
+}
+
+func swap(b byte) byte {
+	return b<<4 | b>>4
+}
+
+func pearson(salt, i, j, k byte) byte {
+	h := vTable[salt]
+	h = vTable[h^i]
+	h = vTable[h^j]
+	return vTable[h^k]
+}
+
+// vTable TLSH使用的pearson置换表
+var vTable = [256]byte{
+	1, 87, 49, 12, 176, 178, 102, 166, 121, 193, 6, 84, 249, 230, 44, 163,
+	14, 197, 213, 181, 161, 85, 218, 80, 64, 239, 24, 226, 236, 142, 38, 200,
+	110, 177, 104, 103, 141, 253, 255, 50, 77, 101, 81, 18, 45, 96, 31, 222,
+	25, 107, 190, 70, 86, 237, 240, 34, 72, 242, 20, 214, 244, 227, 149, 235,
+	97, 234, 57, 22, 60, 250, 82, 175, 208, 5, 127, 199, 111, 62, 135, 248,
+	174, 169, 211, 58, 66, 154, 106, 195, 245, 171, 17, 187, 182, 179, 0, 243,
+	132, 56, 148, 75, 128, 133, 158, 100, 130, 126, 91, 13, 153, 246, 216, 219,
+	119, 68, 223, 78, 83, 88, 201, 99, 122, 11, 92, 32, 136, 114, 52, 10,
+	138, 30, 48, 183, 156, 35, 61, 26, 143, 74, 251, 94, 129, 162, 63, 152,
+	170, 7, 115, 167, 241, 206, 3, 150, 55, 59, 151, 220, 90, 53, 23, 131,
+	125, 173, 15, 238, 79, 95, 89, 16, 105, 137, 225, 224, 217, 160, 37, 123,
+	118, 73, 2, 157, 46, 116, 9, 145, 134, 228, 207, 212, 202, 215, 69, 229,
+	27, 188, 67, 124, 168, 252, 42, 4, 29, 108, 21, 247, 19, 205, 39, 203,
+	233, 40, 186, 147, 198, 192, 155, 33, 164, 191, 98, 204, 165, 180, 117, 76,
+	140, 36, 210, 172, 41, 54, 159, 8, 185, 232, 113, 196, 231, 47, 146, 120,
+	51, 65, 28, 144, 254, 221, 93, 189, 194, 139, 112, 43, 71, 109, 184, 209,
+}
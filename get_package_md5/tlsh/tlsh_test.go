@@ -0,0 +1,108 @@
+package tlsh
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// testData 由LCG生成的伪随机数据，便于在测试中复现输入
+func testData(n int, seed uint32) []byte {
+	b := make([]byte, n)
+	x := seed
+	for i := range b {
+		x = x*1664525 + 1013904223
+		b[i] = byte(x >> 24)
+	}
+	return b
+}
+
+// testInputs mit为glaslos/tlsh的tests/test_file_1，其摘要与参考实现一致
+// 其余的期望值由glaslos/tlsh v0.2.0计算(输出不带"T1"前缀)，b为a每隔16字节取反后的结果
+func testInputs() map[string][]byte {
+	a := testData(1024, 1)
+	b := append([]byte(nil), a...)
+	for i := 0; i < len(b); i += 16 {
+		b[i] ^= 0xff
+	}
+	return map[string][]byte{
+		"mit": []byte(strings.Repeat("MIT License is so cool license that I can't imagine a better one!!\n", 4)),
+		"a":   a,
+		"b":   b,
+		"c":   testData(4096, 2),
+	}
+}
+
+var hashTests = map[string]string{
+	"mit": "T18ED02202FC30802303A002B03B33300FC30A82F83008C2FA000A0080B8BA0E02CCA0C3",
+	"a":   "T10711DC5F62349036F6B2E8D8E8F2CC3B6120DC50587FC67101071F5FF2E065899450B5",
+	"b":   "T17211B55E7A38A422F2E27CD5D9A6CD6B6220DC80485E9A3000030F9AF2A57D45E0C1F5",
+	"c":   "T1C8818EBC42557F6DAFB02601D543398D1B14A2F1205558EFAD53DEA8933FE8FA0C1C92",
+}
+
+func TestHash(t *testing.T) {
+	for name, data := range testInputs() {
+		h := New()
+		h.Write(data)
+		got, err := h.Sum()
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if got != hashTests[name] {
+			t.Errorf("%s: got %s, want %s", name, got, hashTests[name])
+		}
+
+		// 分多次写入的结果应与一次写入相同
+		h = New()
+		for i := 0; i < len(data); i += 7 {
+			h.Write(data[i:min(i+7, len(data))])
+		}
+		if got, _ := h.Sum(); got != hashTests[name] {
+			t.Errorf("%s: chunked writes got %s", name, got)
+		}
+	}
+}
+
+func TestHashInvalid(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"short":    testData(MinSize-1, 1),
+		"constant": make([]byte, 1000),
+	} {
+		h := New()
+		h.Write(data)
+		if got, err := h.Sum(); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%s: got %q, %v, want ErrInvalid", name, got, err)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		a, b string
+		diff int
+	}{
+		{"mit", "mit", 0},
+		{"mit", "a", 382},
+		{"mit", "b", 356},
+		{"mit", "c", 467},
+		{"a", "b", 145},
+		{"a", "c", 373},
+		{"b", "c", 407},
+	}
+	for _, tt := range tests {
+		for _, pair := range [][2]string{{tt.a, tt.b}, {tt.b, tt.a}} {
+			got, err := Diff(hashTests[pair[0]], hashTests[pair[1]])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.diff {
+				t.Errorf("Diff(%s, %s) = %d, want %d", pair[0], pair[1], got, tt.diff)
+			}
+		}
+	}
+
+	if _, err := Diff(hashTests["a"], "T1XYZ"); err == nil {
+		t.Error("invalid hash: want error")
+	}
+}
@@ -0,0 +1,23 @@
+package unarchiver
+
+import (
+	"context"
+	"io"
+)
+
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+// WithContext 包装r，ctx取消后的读取直接返回ctx的错误，用于中断耗时的解压与遍历
+func WithContext(ctx context.Context, r io.Reader) io.Reader {
+	return &ctxReader{ctx: ctx, r: r}
+}
+
+func (c *ctxReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
@@ -0,0 +1,20 @@
+package unarchiver
+
+import (
+	"io"
+	"io/fs"
+)
+
+// Entry 归档中的一个文件，tar、cpio的回调收到的reader为*Entry，可通过Info取得大小、权限等信息
+type Entry struct {
+	io.Reader
+	Info fs.FileInfo
+}
+
+// Info 返回回调reader对应文件的信息，reader不是*Entry时返回nil
+func Info(r io.Reader) fs.FileInfo {
+	if e, ok := r.(*Entry); ok {
+		return e.Info
+	}
+	return nil
+}
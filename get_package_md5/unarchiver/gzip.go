@@ -45,7 +45,7 @@ func ReadTar(r io.Reader, do func(n string, r io.Reader) error) error {
 		if h.FileInfo().IsDir() {
 			continue
 		}
-		if err := do(h.Name, t); err != nil {
+		if err := do(h.Name, &Entry{Reader: t, Info: h.FileInfo()}); err != nil {
 			return err
 		}
 	}
@@ -87,17 +87,14 @@ func ReadTarZst(r io.Reader, do func(n string, r io.Reader) error) error {
 	if err != nil {
 		return err
 	}
+	defer z.Close()
 
 	return ReadTar(z, do)
 }
 
-func ReadCpioXz(r io.Reader, do func(n string, r io.Reader) error) error {
-	x, err := xz.NewReader(r)
-	if err != nil {
-		return err
-	}
-
-	c := cpio.NewReader(x)
+// ReadCpio 遍历cpio中的普通文件
+func ReadCpio(r io.Reader, do func(n string, r io.Reader) error) error {
+	c := cpio.NewReader(r)
 	for {
 		h, err := c.Next()
 		if err == io.EOF {
@@ -109,9 +106,46 @@ func ReadCpioXz(r io.Reader, do func(n string, r io.Reader) error) error {
 		if !h.FileInfo().Mode().IsRegular() {
 			continue
 		}
-		if err := do(h.Name, c); err != nil {
+		if err := do(h.Name, &Entry{Reader: c, Info: h.FileInfo()}); err != nil {
 			return err
 		}
 	}
 	return nil
 }
+
+func ReadCpioXz(r io.Reader, do func(n string, r io.Reader) error) error {
+	x, err := xz.NewReader(r)
+	if err != nil {
+		return err
+	}
+	return ReadCpio(x, do)
+}
+
+func ReadCpioGzip(r io.Reader, do func(n string, r io.Reader) error) error {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	return ReadCpio(gr, do)
+}
+
+func ReadCpioBz2(r io.Reader, do func(n string, r io.Reader) error) error {
+	return ReadCpio(bzip2.NewReader(r), do)
+}
+
+func ReadCpioLzma(r io.Reader, do func(n string, r io.Reader) error) error {
+	l, err := lzma.NewReader(r)
+	if err != nil {
+		return err
+	}
+	return ReadCpio(l, do)
+}
+
+func ReadCpioZst(r io.Reader, do func(n string, r io.Reader) error) error {
+	z, err := zstd.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer z.Close()
+	return ReadCpio(z, do)
+}
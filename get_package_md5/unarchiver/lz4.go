@@ -0,0 +1,57 @@
+package unarchiver
+
+import "github.com/pkg/errors"
+
+var errLZ4 = errors.New("corrupt lz4 block")
+
+// decodeLZ4Block 解压lz4的block格式(squashfs中的lz4块不含frame头)，limit为解压后的最大长度
+// 每个序列为token、字面量以及2字节的距离和匹配长度，最后一个序列只有字面量
+func decodeLZ4Block(src []byte, limit int) ([]byte, error) {
+	dst := make([]byte, 0, limit)
+	i := 0
+	// length token中的4位长度为15时，之后每个255字节继续累加，直到不是255的字节
+	length := func(n int) (int, bool) {
+		if n != 15 {
+			return n, true
+		}
+		for i < len(src) {
+			b := src[i]
+			i++
+			n += int(b)
+			if b != 255 {
+				return n, true
+			}
+		}
+		return 0, false
+	}
+
+	for i < len(src) {
+		token := src[i]
+		i++
+		n, ok := length(int(token >> 4))
+		if !ok || n > len(src)-i || n > limit-len(dst) {
+			return nil, errLZ4
+		}
+		dst = append(dst, src[i:i+n]...)
+		i += n
+		if i == len(src) {
+			return dst, nil
+		}
+
+		if len(src)-i < 2 {
+			return nil, errLZ4
+		}
+		dist := int(src[i]) | int(src[i+1])<<8
+		i += 2
+		m, ok := length(int(token & 15))
+		m += 4
+		if !ok || dist == 0 || dist > len(dst) || m > limit-len(dst) {
+			return nil, errLZ4
+		}
+		// 距离小于长度时与刚输出的内容重叠，需要逐字节复制
+		for j := 0; j < m; j++ {
+			dst = append(dst, dst[len(dst)-dist])
+		}
+	}
+	return nil, errLZ4
+}
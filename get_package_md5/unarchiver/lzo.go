@@ -0,0 +1,123 @@
+package unarchiver
+
+import "github.com/pkg/errors"
+
+var errLZO = errors.New("corrupt lzo data")
+
+// decodeLZO1X 解压lzo1x格式(与内核lzo1x_decompress_safe相同)，limit为解压后的最大长度
+// 指令的含义取决于上一条指令之后复制的字面量个数state，参见内核文档Documentation/staging/lzo.rst
+func decodeLZO1X(src []byte, limit int) (dst []byte, err error) {
+	defer func() {
+		// 输入不完整时下标越界，与其他损坏的情况一样返回errLZO
+		if e := recover(); e != nil {
+			dst, err = nil, errLZO
+		}
+	}()
+
+	dst = make([]byte, 0, limit)
+	ip := 0
+	literals := func(n int) {
+		if n > limit-len(dst) {
+			panic(errLZO)
+		}
+		dst = append(dst, src[ip:ip+n]...)
+		ip += n
+	}
+	// match 复制距离末尾dist处的n个字节，可能与刚输出的内容重叠
+	match := func(dist, n int) {
+		if dist > len(dst) || n > limit-len(dst) {
+			panic(errLZO)
+		}
+		for i := 0; i < n; i++ {
+			dst = append(dst, dst[len(dst)-dist])
+		}
+	}
+	// zeros 长度字段为0时，之后每个0字节表示长度再加255，直到一个非0字节
+	zeros := func() int {
+		n := 0
+		for src[ip] == 0 {
+			n += 255
+			ip++
+		}
+		n += int(src[ip])
+		ip++
+		return n
+	}
+	le16 := func() int {
+		v := int(src[ip]) | int(src[ip+1])<<8
+		ip += 2
+		return v
+	}
+
+	state := 0
+	// 第一个字节大于17时表示开头的字面量个数
+	if src[0] > 17 {
+		t := int(src[0]) - 17
+		ip++
+		literals(t)
+		state = min(t, 4)
+	}
+	for {
+		t := int(src[ip])
+		ip++
+		var dist, n, next int
+		switch {
+		case t < 16 && state == 0:
+			// 较长的一段字面量
+			if t == 0 {
+				t = 15 + zeros()
+			}
+			literals(t + 3)
+			state = 4
+			continue
+		case t < 16 && state < 4:
+			// 紧跟在少量字面量之后的2字节匹配，距离在1KB以内
+			next = t & 3
+			dist = 1 + t>>2 + int(src[ip])<<2
+			ip++
+			n = 2
+		case t < 16:
+			// 紧跟在一段字面量之后的3字节匹配，距离为2KB到3KB
+			next = t & 3
+			dist = 1 + 0x800 + t>>2 + int(src[ip])<<2
+			ip++
+			n = 3
+		case t >= 64:
+			// M2，3到8字节，距离在2KB以内
+			next = t & 3
+			dist = 1 + (t>>2)&7 + int(src[ip])<<3
+			ip++
+			n = t>>5 + 1
+		case t >= 32:
+			// M3，距离在16KB以内
+			n = t & 31
+			if n == 0 {
+				n = 31 + zeros()
+			}
+			n += 2
+			v := le16()
+			dist = 1 + v>>2
+			next = v & 3
+		default:
+			// M4，距离为16KB到48KB，距离为0时是结束标记
+			n = t & 7
+			if n == 0 {
+				n = 7 + zeros()
+			}
+			n += 2
+			v := le16()
+			dist = (t&8)<<11 + v>>2
+			next = v & 3
+			if dist == 0 {
+				if n != 3 {
+					return nil, errLZO
+				}
+				return dst, nil
+			}
+			dist += 0x4000
+		}
+		match(dist, n)
+		literals(next)
+		state = next
+	}
+}
@@ -0,0 +1,566 @@
+package unarchiver
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"io"
+	"io/fs"
+	"path"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+	"github.com/ulikunitz/xz"
+	"github.com/ulikunitz/xz/lzma"
+)
+
+// squashfs 4.0，snap与AppImage使用的只读文件系统，超级块中各表的偏移都相对于squashfs的起始位置
+const (
+	squashfsMagic = 0x73717368
+	// squashfsMetaSize 元数据块解压后的最大长度，inode、目录、分片表都存放在元数据块中
+	squashfsMetaSize = 8192
+	// squashfsMetaUncompressed 元数据块头部的该位表示未压缩，其余位为块在磁盘上的长度
+	squashfsMetaUncompressed = 1 << 15
+	// squashfsDataUncompressed 数据块及分片长度的该位表示未压缩
+	squashfsDataUncompressed = 1 << 24
+	squashfsNoFragment       = 0xffffffff
+	// squashfsFragsPerBlock 每个元数据块中的分片表项数(8192/16)
+	squashfsFragsPerBlock = 512
+	// maxSquashfsDepth 目录的最大层数，防止损坏的镜像导致无限递归
+	maxSquashfsDepth = 128
+	// maxSquashfsBlocks 单个文件的最大数据块数
+	maxSquashfsBlocks = 1 << 24
+)
+
+// inode类型，目录项中只使用基本类型
+const (
+	squashfsDir     = 1
+	squashfsFile    = 2
+	squashfsExtDir  = 8
+	squashfsExtFile = 9
+)
+
+// squashfsCompressors 超级块中的压缩方式编号
+var squashfsCompressors = map[uint16]string{
+	1: "gzip",
+	2: "lzma",
+	3: "lzo",
+	4: "xz",
+	5: "lz4",
+	6: "zstd",
+}
+
+// ErrSquashfsCompression squashfs使用了不支持的压缩方式
+var ErrSquashfsCompression = errors.New("unsupported squashfs compression")
+
+type squashfsSuper struct {
+	Magic       uint32
+	Inodes      uint32
+	MkfsTime    uint32
+	BlockSize   uint32
+	Fragments   uint32
+	Compression uint16
+	BlockLog    uint16
+	Flags       uint16
+	IDs         uint16
+	Major       uint16
+	Minor       uint16
+	RootInode   uint64
+	BytesUsed   uint64
+	IDTable     uint64
+	XattrTable  uint64
+	InodeTable  uint64
+	DirTable    uint64
+	FragTable   uint64
+	ExportTable uint64
+}
+
+type squashfsFragment struct {
+	Start  uint64
+	Size   uint32
+	Unused uint32
+}
+
+// squashfsInode 目录或普通文件的inode，其他类型只保留公共头部
+type squashfsInode struct {
+	typ   uint16
+	mode  uint16
+	mtime uint32
+	// 目录的内容在目录表中的位置，size比实际长度多3(.与..)
+	dirBlock  uint32
+	dirOffset uint16
+	dirSize   uint32
+	// 文件的数据块从blocksStart开始连续存放，末尾不足一块的部分可能存放在分片中
+	blocksStart uint64
+	fileSize    uint64
+	fragment    uint32
+	fragOffset  uint32
+	blocks      []uint32
+}
+
+type squashfsDirEntry struct {
+	name string
+	typ  uint16
+	ref  uint64
+}
+
+type squashfsReader struct {
+	r     io.ReaderAt
+	sb    squashfsSuper
+	zstd  *zstd.Decoder
+	frags []squashfsFragment
+	// 最近读取的分片块，小文件通常集中在同一个分片中
+	fragIndex uint32
+	fragData  []byte
+}
+
+// IsSquashfs 判断r是否以squashfs的超级块开头
+func IsSquashfs(r io.ReaderAt) bool {
+	var magic [4]byte
+	if _, err := r.ReadAt(magic[:], 0); err != nil {
+		return false
+	}
+	return binary.LittleEndian.Uint32(magic[:]) == squashfsMagic
+}
+
+// ReadSquashfs 遍历squashfs中的普通文件，r需从超级块开始，回调收到的reader为*Entry
+// 符号链接、设备等其他类型的文件被忽略
+func ReadSquashfs(r io.ReaderAt, do func(n string, r io.Reader) error) error {
+	s := &squashfsReader{r: r, fragIndex: squashfsNoFragment}
+	if err := binary.Read(io.NewSectionReader(r, 0, 96), binary.LittleEndian, &s.sb); err != nil {
+		return errors.WithMessagef(err, "read superblock")
+	}
+	if s.sb.Magic != squashfsMagic {
+		return errors.Errorf("invalid squashfs magic %#x", s.sb.Magic)
+	}
+	if s.sb.Major != 4 {
+		return errors.Errorf("unsupported squashfs version %d.%d", s.sb.Major, s.sb.Minor)
+	}
+	if s.sb.BlockLog < 12 || s.sb.BlockLog > 20 || s.sb.BlockSize != 1<<s.sb.BlockLog {
+		return errors.Errorf("invalid squashfs block size %d", s.sb.BlockSize)
+	}
+	switch squashfsCompressors[s.sb.Compression] {
+	case "":
+		return errors.WithMessagef(ErrSquashfsCompression, "compression id %d", s.sb.Compression)
+	case "zstd":
+		d, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return err
+		}
+		defer d.Close()
+		s.zstd = d
+	}
+
+	root, err := s.inode(s.sb.RootInode)
+	if err != nil {
+		return errors.WithMessagef(err, "read root inode")
+	}
+	return s.walk("", root, 0, do)
+}
+
+func (s *squashfsReader) walk(dir string, in *squashfsInode, depth int, do func(n string, r io.Reader) error) error {
+	if depth > maxSquashfsDepth {
+		return errors.Errorf("directory %s nested too deep", dir)
+	}
+	entries, err := s.readDir(in)
+	if err != nil {
+		return errors.WithMessagef(err, "read directory /%s", dir)
+	}
+	for _, e := range entries {
+		n := path.Join(dir, e.name)
+		if e.typ != squashfsDir && e.typ != squashfsFile {
+			continue
+		}
+		child, err := s.inode(e.ref)
+		if err != nil {
+			return errors.WithMessagef(err, "read inode of /%s", n)
+		}
+		switch child.typ {
+		case squashfsDir, squashfsExtDir:
+			if err := s.walk(n, child, depth+1, do); err != nil {
+				return err
+			}
+		case squashfsFile, squashfsExtFile:
+			f := &squashfsData{s: s, in: child, pos: int64(child.blocksStart), remain: child.fileSize}
+			if err := do(n, &Entry{Reader: f, Info: &squashfsInfo{name: e.name, in: child}}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readAt 读满p，读到结尾时返回io.ErrUnexpectedEOF
+func (s *squashfsReader) readAt(p []byte, off int64) error {
+	n, err := s.r.ReadAt(p, off)
+	if n == len(p) {
+		return nil
+	}
+	if err == nil || err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// decompress 解压一个块，limit为解压后的最大长度
+func (s *squashfsReader) decompress(data []byte, limit int64) ([]byte, error) {
+	var (
+		r   io.Reader
+		err error
+	)
+	switch s.sb.Compression {
+	case 1:
+		r, err = zlib.NewReader(bytes.NewReader(data))
+	case 2:
+		r, err = lzma.NewReader(bytes.NewReader(data))
+	case 3:
+		return decodeLZO1X(data, int(limit))
+	case 4:
+		r, err = xz.NewReader(bytes.NewReader(data))
+	case 5:
+		return decodeLZ4Block(data, int(limit))
+	case 6:
+		return s.zstd.DecodeAll(data, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(io.LimitReader(r, limit))
+}
+
+// metaReader 顺序读取从某个元数据块开始的内容，跨块时自动读取下一块
+type metaReader struct {
+	s   *squashfsReader
+	pos int64
+	buf []byte
+}
+
+// meta 返回位于start处的元数据块中从offset开始的内容
+func (s *squashfsReader) meta(start uint64, offset uint16) (*metaReader, error) {
+	m := &metaReader{s: s, pos: int64(start)}
+	if err := m.next(); err != nil {
+		return nil, err
+	}
+	if int(offset) > len(m.buf) {
+		return nil, errors.Errorf("metadata offset %d out of block at %d", offset, start)
+	}
+	m.buf = m.buf[offset:]
+	return m, nil
+}
+
+func (m *metaReader) next() error {
+	var head [2]byte
+	if err := m.s.readAt(head[:], m.pos); err != nil {
+		return errors.WithMessagef(err, "read metadata header at %d", m.pos)
+	}
+	h := binary.LittleEndian.Uint16(head[:])
+	size := int64(h &^ squashfsMetaUncompressed)
+	if size > squashfsMetaSize {
+		return errors.Errorf("invalid metadata size %d at %d", size, m.pos)
+	}
+	data := make([]byte, size)
+	if err := m.s.readAt(data, m.pos+2); err != nil {
+		return errors.WithMessagef(err, "read metadata at %d", m.pos)
+	}
+	if h&squashfsMetaUncompressed == 0 {
+		var err error
+		if data, err = m.s.decompress(data, squashfsMetaSize); err != nil {
+			return errors.WithMessagef(err, "decompress metadata at %d", m.pos)
+		}
+	}
+	m.pos += 2 + size
+	m.buf = data
+	return nil
+}
+
+func (m *metaReader) Read(p []byte) (int, error) {
+	for len(m.buf) == 0 {
+		if err := m.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, m.buf)
+	m.buf = m.buf[n:]
+	return n, nil
+}
+
+// inode 读取引用ref指向的inode，ref的高位为元数据块相对inode表的偏移，低16位为块内偏移
+func (s *squashfsReader) inode(ref uint64) (*squashfsInode, error) {
+	m, err := s.meta(s.sb.InodeTable+ref>>16, uint16(ref))
+	if err != nil {
+		return nil, err
+	}
+	var h struct {
+		Type   uint16
+		Mode   uint16
+		UID    uint16
+		GID    uint16
+		Mtime  uint32
+		Number uint32
+	}
+	if err := binary.Read(m, binary.LittleEndian, &h); err != nil {
+		return nil, err
+	}
+	in := &squashfsInode{typ: h.Type, mode: h.Mode, mtime: h.Mtime}
+
+	switch h.Type {
+	case squashfsDir:
+		var d struct {
+			Block  uint32
+			Links  uint32
+			Size   uint16
+			Offset uint16
+			Parent uint32
+		}
+		if err := binary.Read(m, binary.LittleEndian, &d); err != nil {
+			return nil, err
+		}
+		in.dirBlock, in.dirOffset, in.dirSize = d.Block, d.Offset, uint32(d.Size)
+	case squashfsExtDir:
+		var d struct {
+			Links   uint32
+			Size    uint32
+			Block   uint32
+			Parent  uint32
+			Indexes uint16
+			Offset  uint16
+			Xattr   uint32
+		}
+		if err := binary.Read(m, binary.LittleEndian, &d); err != nil {
+			return nil, err
+		}
+		in.dirBlock, in.dirOffset, in.dirSize = d.Block, d.Offset, d.Size
+	case squashfsFile:
+		var f struct {
+			Start    uint32
+			Fragment uint32
+			Offset   uint32
+			Size     uint32
+		}
+		if err := binary.Read(m, binary.LittleEndian, &f); err != nil {
+			return nil, err
+		}
+		in.blocksStart, in.fragment, in.fragOffset, in.fileSize = uint64(f.Start), f.Fragment, f.Offset, uint64(f.Size)
+	case squashfsExtFile:
+		var f struct {
+			Start    uint64
+			Size     uint64
+			Sparse   uint64
+			Links    uint32
+			Fragment uint32
+			Offset   uint32
+			Xattr    uint32
+		}
+		if err := binary.Read(m, binary.LittleEndian, &f); err != nil {
+			return nil, err
+		}
+		in.blocksStart, in.fragment, in.fragOffset, in.fileSize = f.Start, f.Fragment, f.Offset, f.Size
+	default:
+		return in, nil
+	}
+
+	if h.Type == squashfsFile || h.Type == squashfsExtFile {
+		// 有分片时末尾不足一块的部分不占用数据块
+		n := in.fileSize / uint64(s.sb.BlockSize)
+		if in.fragment == squashfsNoFragment && in.fileSize%uint64(s.sb.BlockSize) != 0 {
+			n++
+		}
+		if n > maxSquashfsBlocks {
+			return nil, errors.Errorf("too many blocks %d", n)
+		}
+		in.blocks = make([]uint32, n)
+		if err := binary.Read(m, binary.LittleEndian, in.blocks); err != nil {
+			return nil, err
+		}
+	}
+	return in, nil
+}
+
+// readDir 读取目录的所有项，目录内容由若干组头部及其后的目录项组成
+func (s *squashfsReader) readDir(in *squashfsInode) ([]squashfsDirEntry, error) {
+	if in.dirSize <= 3 {
+		return nil, nil
+	}
+	m, err := s.meta(s.sb.DirTable+uint64(in.dirBlock), in.dirOffset)
+	if err != nil {
+		return nil, err
+	}
+	r := io.LimitReader(m, int64(in.dirSize-3))
+
+	var entries []squashfsDirEntry
+	for {
+		var h struct {
+			Count uint32
+			Start uint32
+			Inode uint32
+		}
+		if err := binary.Read(r, binary.LittleEndian, &h); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		// 每组最多256项，Count比实际项数少1
+		if h.Count >= 256 {
+			return nil, errors.Errorf("invalid directory entry count %d", h.Count+1)
+		}
+		for i := uint32(0); i <= h.Count; i++ {
+			var e struct {
+				Offset     uint16
+				InodeDelta int16
+				Type       uint16
+				NameSize   uint16
+			}
+			if err := binary.Read(r, binary.LittleEndian, &e); err != nil {
+				return nil, err
+			}
+			name := make([]byte, int(e.NameSize)+1)
+			if _, err := io.ReadFull(r, name); err != nil {
+				return nil, err
+			}
+			entries = append(entries, squashfsDirEntry{
+				name: string(name),
+				typ:  e.Type,
+				ref:  uint64(h.Start)<<16 | uint64(e.Offset),
+			})
+		}
+	}
+	return entries, nil
+}
+
+// fragment 返回第i个分片块解压后的内容，分片表在第一次使用时读取
+func (s *squashfsReader) fragment(i uint32) ([]byte, error) {
+	if i == s.fragIndex {
+		return s.fragData, nil
+	}
+	if s.frags == nil {
+		if err := s.readFragTable(); err != nil {
+			return nil, errors.WithMessagef(err, "read fragment table")
+		}
+	}
+	if int(i) >= len(s.frags) {
+		return nil, errors.Errorf("fragment %d out of range", i)
+	}
+	data, err := s.readBlock(int64(s.frags[i].Start), s.frags[i].Size)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "read fragment %d", i)
+	}
+	s.fragIndex, s.fragData = i, data
+	return data, nil
+}
+
+// readFragTable 分片表的索引为各元数据块的位置，每块存放512项
+func (s *squashfsReader) readFragTable() error {
+	count := int(s.sb.Fragments)
+	blocks := (count + squashfsFragsPerBlock - 1) / squashfsFragsPerBlock
+	index := make([]uint64, blocks)
+	if err := binary.Read(io.NewSectionReader(s.r, int64(s.sb.FragTable), int64(blocks)*8), binary.LittleEndian, index); err != nil {
+		return err
+	}
+	s.frags = make([]squashfsFragment, 0, count)
+	for _, start := range index {
+		m, err := s.meta(start, 0)
+		if err != nil {
+			return err
+		}
+		n := count - len(s.frags)
+		if n > squashfsFragsPerBlock {
+			n = squashfsFragsPerBlock
+		}
+		frags := make([]squashfsFragment, n)
+		if err := binary.Read(m, binary.LittleEndian, frags); err != nil {
+			return err
+		}
+		s.frags = append(s.frags, frags...)
+	}
+	return nil
+}
+
+// readBlock 读取位于pos的数据块或分片块，size的低24位为磁盘上的长度
+func (s *squashfsReader) readBlock(pos int64, size uint32) ([]byte, error) {
+	data := make([]byte, size&(squashfsDataUncompressed-1))
+	if err := s.readAt(data, pos); err != nil {
+		return nil, err
+	}
+	if size&squashfsDataUncompressed != 0 {
+		return data, nil
+	}
+	return s.decompress(data, int64(s.sb.BlockSize))
+}
+
+// squashfsData 按块读取文件内容
+type squashfsData struct {
+	s      *squashfsReader
+	in     *squashfsInode
+	block  int
+	pos    int64
+	remain uint64
+	buf    []byte
+}
+
+func (f *squashfsData) Read(p []byte) (int, error) {
+	for len(f.buf) == 0 {
+		if f.remain == 0 {
+			return 0, io.EOF
+		}
+		if err := f.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, f.buf)
+	f.buf = f.buf[n:]
+	f.remain -= uint64(n)
+	return n, nil
+}
+
+func (f *squashfsData) next() error {
+	want := uint64(f.s.sb.BlockSize)
+	if f.remain < want {
+		want = f.remain
+	}
+	if f.block < len(f.in.blocks) {
+		size := f.in.blocks[f.block]
+		f.block++
+		// 长度为0的块为稀疏块，内容全为0
+		if size == 0 {
+			f.buf = make([]byte, want)
+			return nil
+		}
+		data, err := f.s.readBlock(f.pos, size)
+		if err != nil {
+			return errors.WithMessagef(err, "read block at %d", f.pos)
+		}
+		f.pos += int64(size & (squashfsDataUncompressed - 1))
+		if uint64(len(data)) < want {
+			return errors.Errorf("short block at %d", f.pos)
+		}
+		f.buf = data[:want]
+		return nil
+	}
+	if f.in.fragment == squashfsNoFragment {
+		return io.ErrUnexpectedEOF
+	}
+	data, err := f.s.fragment(f.in.fragment)
+	if err != nil {
+		return err
+	}
+	start := uint64(f.in.fragOffset)
+	if start+want > uint64(len(data)) {
+		return errors.Errorf("fragment %d offset %d out of range", f.in.fragment, start)
+	}
+	f.buf = data[start : start+want]
+	return nil
+}
+
+// squashfsInfo 实现fs.FileInfo，Mode只保留权限位
+type squashfsInfo struct {
+	name string
+	in   *squashfsInode
+}
+
+func (i *squashfsInfo) Name() string       { return i.name }
+func (i *squashfsInfo) Size() int64        { return int64(i.in.fileSize) }
+func (i *squashfsInfo) Mode() fs.FileMode  { return fs.FileMode(i.in.mode) & fs.ModePerm }
+func (i *squashfsInfo) ModTime() time.Time { return time.Unix(int64(i.in.mtime), 0) }
+func (i *squashfsInfo) IsDir() bool        { return false }
+func (i *squashfsInfo) Sys() interface{}   { return nil }
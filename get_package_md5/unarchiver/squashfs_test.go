@@ -0,0 +1,54 @@
+package unarchiver
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// squashfsBlock lz4、lzo测试向量解压后的内容，包含长字面量、重叠匹配以及较长的重复
+func squashfsBlock() []byte {
+	b := []byte("\x7fELF\x02\x01\x01")
+	b = append(b, make([]byte, 40)...)
+	b = append(b, strings.Repeat("squashfs block of text, ", 6)...)
+	return append(b, strings.Repeat("ab", 31)...)
+}
+
+func TestDecodeSquashfsBlock(t *testing.T) {
+	want := squashfsBlock()
+	tests := []struct {
+		name   string
+		decode func(src []byte, limit int) ([]byte, error)
+		// lz4由lz4 -9生成，lzo已用其他的lzo1x实现核对
+		data string
+	}{
+		{"lz4", decodeLZ4Block, "8f7f454c4602010100010014ff09737175617368667320626c6f636b206f6620746578742c201800652f6162020024506261626162"},
+		{"lzo", decodeLZO1X, "197f454c4602010100200600000006737175617368667320626c6f636b206f6620746578742c2020575e006162040020190400110000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := hex.DecodeString(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := tt.decode(data, len(want))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Fatalf("got %q, want %q", got, want)
+			}
+
+			// 截断的输入以及超过limit的输出都应返回错误
+			for _, n := range []int{0, 1, len(data) / 2, len(data) - 1} {
+				if _, err := tt.decode(data[:n], len(want)); err == nil {
+					t.Errorf("truncated to %d bytes: want error", n)
+				}
+			}
+			if _, err := tt.decode(data, len(want)-1); err == nil {
+				t.Errorf("limit %d: want error", len(want)-1)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"compress/zlib"
+	"debug/buildinfo"
+	"debug/elf"
+	"encoding/json"
+	"get_package_md5/model"
+	"io"
+	"runtime/debug"
+)
+
+// auditableSection cargo-auditable写入的依赖信息所在的section，内容为zlib压缩的json
+const auditableSection = ".dep-v0"
+
+// maxAuditableSize 解压cargo-auditable依赖信息的上限
+const maxAuditableSize = 8 << 20
+
+// BuildInfo 读取二进制中嵌入的构建信息，支持go的buildinfo以及rust的cargo-auditable，都没有时返回nil
+func BuildInfo(r io.ReaderAt) *model.BuildInfo {
+	if info, err := buildinfo.Read(r); err == nil {
+		return goBuildInfo(info)
+	}
+	return rustBuildInfo(r)
+}
+
+func goBuildInfo(info *debug.BuildInfo) *model.BuildInfo {
+	b := &model.BuildInfo{
+		Lang:      "go",
+		Toolchain: info.GoVersion,
+		Main:      goModule(&info.Main),
+	}
+	if b.Main == nil && info.Path != "" {
+		b.Main = &model.Module{Path: info.Path}
+	}
+	for _, d := range info.Deps {
+		if m := goModule(d); m != nil {
+			b.Deps = append(b.Deps, m)
+		}
+	}
+	return b
+}
+
+func goModule(m *debug.Module) *model.Module {
+	if m == nil || m.Path == "" {
+		return nil
+	}
+	mod := &model.Module{Path: m.Path, Version: m.Version, Sum: m.Sum}
+	if m.Replace != nil {
+		mod.Replace = goModule(m.Replace)
+	}
+	return mod
+}
+
+// auditable cargo-auditable的依赖信息格式
+type auditable struct {
+	Packages []struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+		Source  string `json:"source"`
+		Kind    string `json:"kind"`
+		Root    bool   `json:"root"`
+	} `json:"packages"`
+}
+
+func rustBuildInfo(r io.ReaderAt) *model.BuildInfo {
+	f, err := elf.NewFile(r)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	s := f.Section(auditableSection)
+	if s == nil {
+		return nil
+	}
+	zr, err := zlib.NewReader(s.Open())
+	if err != nil {
+		return nil
+	}
+	defer zr.Close()
+
+	var a auditable
+	if err := json.NewDecoder(io.LimitReader(zr, maxAuditableSize)).Decode(&a); err != nil {
+		return nil
+	}
+
+	b := &model.BuildInfo{Lang: "rust"}
+	for _, p := range a.Packages {
+		m := &model.Module{Path: p.Name, Version: p.Version, Source: p.Source}
+		if p.Root && b.Main == nil {
+			b.Main = m
+			continue
+		}
+		// 仅构建时使用的依赖不会进入二进制
+		if p.Kind == "build" {
+			continue
+		}
+		b.Deps = append(b.Deps, m)
+	}
+	return b
+}
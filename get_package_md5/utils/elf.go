@@ -2,7 +2,12 @@ package utils
 
 import (
 	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/hex"
+	"get_package_md5/model"
+	"get_package_md5/tlsh"
+	"io"
 	"strings"
 
 	"github.com/h2non/filetype"
@@ -11,6 +16,15 @@ import (
 
 var nonBinaryFileExtensions = []string{".txt", ".csv", ".xml", ".json", ".html", ".md", ".yml", ".yaml", ".ini", ".conf", ".bat", ".sh", ".py", ".java", ".cpp", ".js", ".go", ".h", ".c"}
 
+// headerSize filetype识别文件类型需要的文件头长度
+const headerSize = 261
+
+// MaxMemberSize 包内单个文件的大小上限，超过的ELF文件不计算hash，0表示不限制
+var MaxMemberSize int64 = 512 << 20
+
+// ErrMemberTooLarge 文件超过MaxMemberSize
+var ErrMemberTooLarge = errors2.New("member too large")
+
 func NoBinary(n string) bool {
 	for _, suffix := range nonBinaryFileExtensions {
 		if strings.HasSuffix(n, suffix) {
@@ -20,17 +34,57 @@ func NoBinary(n string) bool {
 	return false
 }
 
-func CheckElf(data []byte) (bool, string, error) {
-	buffer := make([]byte, 261)
-	copy(buffer, data)
-	t, err := filetype.Get(buffer)
+// HashElf 读取文件头判断r是否为ELF，是则边读边计算md5、sha1、sha256以及TLSH并解析ELF元数据，不是ELF时返回nil
+// 非ELF文件只读取文件头，剩余内容由归档reader跳过，不会载入内存
+func HashElf(r io.Reader) (*model.File, error) {
+	header := make([]byte, headerSize)
+	n, err := io.ReadFull(r, header)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, errors2.WithMessagef(err, "read header")
+	}
+	header = header[:n]
+
+	t, err := filetype.Get(header)
 	if err != nil {
-		return false, "", errors2.WithMessagef(err, "get file type")
+		return nil, errors2.WithMessagef(err, "get file type")
+	}
+	if t.Extension != "elf" {
+		return nil, nil
+	}
+
+	// debug/elf需要随机访问，读取的同时缓存文件内容
+	sp := new(spool)
+	defer sp.Close()
+	m, s1, s256, lsh := md5.New(), sha1.New(), sha256.New(), tlsh.New()
+	w := io.MultiWriter(m, s1, s256, lsh, sp)
+	if _, err := w.Write(header); err != nil {
+		return nil, errors2.WithMessagef(err, "spool elf")
+	}
+	if MaxMemberSize > 0 {
+		r = io.LimitReader(r, MaxMemberSize-int64(n)+1)
+	}
+	written, err := io.Copy(w, r)
+	if err != nil {
+		return nil, errors2.WithMessagef(err, "read elf")
+	}
+	size := int64(n) + written
+	if MaxMemberSize > 0 && size > MaxMemberSize {
+		return nil, errors2.WithMessagef(ErrMemberTooLarge, "larger than %d bytes", MaxMemberSize)
+	}
+	f := &model.File{
+		Size:   size,
+		MD5:    hex.EncodeToString(m.Sum(nil)),
+		SHA1:   hex.EncodeToString(s1.Sum(nil)),
+		SHA256: hex.EncodeToString(s256.Sum(nil)),
+		Elf:    ElfInfo(sp.ReaderAt()),
+		Build:  BuildInfo(sp.ReaderAt()),
 	}
-	if t.Extension == "elf" {
-		return true, CalculateMd5(data), nil // ELF 二进制文件
+	// 过小或内容过于单一的文件没有TLSH
+	if th, err := lsh.Sum(); err == nil {
+		f.TLSH = th
+		f.TLSHBands = tlsh.Bands(th)
 	}
-	return false, "", nil
+	return f, nil
 }
 
 func CalculateMd5(b []byte) string {
@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/hex"
+	"get_package_md5/model"
+	"io"
+	"strings"
+)
+
+// ntGnuBuildID GNU build-id note的类型
+const ntGnuBuildID = 3
+
+var elfTypes = map[elf.Type]string{
+	elf.ET_REL:  "rel",
+	elf.ET_EXEC: "exec",
+	elf.ET_DYN:  "dyn",
+	elf.ET_CORE: "core",
+}
+
+// ElfInfo 使用debug/elf读取ELF的元数据，无法解析时返回nil
+func ElfInfo(r io.ReaderAt) *model.ElfInfo {
+	f, err := elf.NewFile(r)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	info := &model.ElfInfo{
+		Machine:  strings.ToLower(strings.TrimPrefix(f.Machine.String(), "EM_")),
+		Class:    strings.TrimPrefix(f.Class.String(), "ELFCLASS"),
+		Endian:   "little",
+		Type:     elfTypes[f.Type],
+		Stripped: f.Section(".symtab") == nil,
+	}
+	if f.Data == elf.ELFDATA2MSB {
+		info.Endian = "big"
+	}
+	if info.Type == "" {
+		info.Type = f.Type.String()
+	}
+
+	for _, p := range f.Progs {
+		switch p.Type {
+		case elf.PT_INTERP:
+			if data, err := io.ReadAll(p.Open()); err == nil {
+				info.Interpreter = string(bytes.TrimRight(data, "\x00"))
+			}
+		case elf.PT_NOTE:
+			if info.BuildID == "" {
+				if data, err := io.ReadAll(p.Open()); err == nil {
+					info.BuildID = buildID(f, data)
+				}
+			}
+		}
+	}
+	// 没有程序头的可重定位文件从section中查找
+	if s := f.Section(".note.gnu.build-id"); info.BuildID == "" && s != nil {
+		if data, err := s.Data(); err == nil {
+			info.BuildID = buildID(f, data)
+		}
+	}
+
+	if sonames, err := f.DynString(elf.DT_SONAME); err == nil && len(sonames) > 0 {
+		info.Soname = sonames[0]
+	}
+	if needed, err := f.DynString(elf.DT_NEEDED); err == nil {
+		info.Needed = needed
+	}
+	return info
+}
+
+// buildID 在note数据中查找GNU build-id
+func buildID(f *elf.File, data []byte) string {
+	align := func(n uint32) int { return int((n + 3) &^ 3) }
+	for len(data) >= 12 {
+		namesz := f.ByteOrder.Uint32(data[0:4])
+		descsz := f.ByteOrder.Uint32(data[4:8])
+		typ := f.ByteOrder.Uint32(data[8:12])
+		data = data[12:]
+		if int64(namesz) > int64(len(data)) || int64(descsz) > int64(len(data)) ||
+			align(namesz)+align(descsz) > len(data) {
+			return ""
+		}
+		name := string(bytes.TrimRight(data[:namesz], "\x00"))
+		desc := data[align(namesz) : align(namesz)+int(descsz)]
+		if typ == ntGnuBuildID && name == "GNU" {
+			return hex.EncodeToString(desc)
+		}
+		data = data[align(namesz)+align(descsz):]
+	}
+	return ""
+}
@@ -34,13 +34,17 @@ var (
 	}
 )
 
-func CheckLicense(n string, data []byte) (*model.License, error) {
+// MaxLicenseSize 读取许可证文件的大小上限，超出部分不参与识别
+const MaxLicenseSize = 1 << 20
+
+// IsLicenseFile 根据文件名判断是否为许可证文件
+func IsLicenseFile(n string) bool {
 	for i, ext := range fileExtensions {
 		if strings.HasSuffix(n, ext) {
 			break
 		}
 		if i == len(fileExtensions)-1 {
-			return nil, nil
+			return false
 		}
 	}
 	for i, regStr := range licenseFileNames {
@@ -49,10 +53,22 @@ func CheckLicense(n string, data []byte) (*model.License, error) {
 			break
 		}
 		if i == len(licenseFileNames)-1 {
-			return nil, nil
+			return false
 		}
 	}
+	return true
+}
+
+func CheckLicense(n string, data []byte) (*model.License, error) {
+	if !IsLicenseFile(n) {
+		return nil, nil
+	}
 
+	return ScanLicense(n, data), nil
+}
+
+// ScanLicense 识别data中的许可证，不检查文件名，没有识别出许可证时返回nil
+func ScanLicense(n string, data []byte) *model.License {
 	lic := new(model.License)
 	cov := licensecheck.Scan(data)
 	lic.Per = cov.Percent
@@ -62,8 +78,8 @@ func CheckLicense(n string, data []byte) (*model.License, error) {
 	}
 	//licensecheck.BuiltinLicenses()
 	if len(lic.Names) == 0 {
-		return nil, nil
+		return nil
 	}
 
-	return lic, nil
+	return lic
 }
@@ -3,14 +3,29 @@ package utils
 import (
 	"encoding/json"
 	"os"
+	"path/filepath"
 )
 
+// SaveJson 先写入同目录下的临时文件再重命名，中断时不会留下写了一半的文件
 func SaveJson(data interface{}, out string) error {
-	f, err := os.OpenFile(out, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	f, err := os.CreateTemp(filepath.Dir(out), filepath.Base(out)+".*.tmp")
 	if err != nil {
 		return err
 	}
-	defer f.Close()
+	tmp := f.Name()
 
-	return json.NewEncoder(f).Encode(data)
+	if err := json.NewEncoder(f).Encode(data); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Chmod(tmp, 0644); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, out)
 }
@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// spoolMemory 超过该大小的内容转存到临时文件
+const spoolMemory = 16 << 20
+
+// spool 缓存流式读取的内容以便随机访问，较小的内容保存在内存中，较大的写入临时文件
+type spool struct {
+	buf  bytes.Buffer
+	file *os.File
+	size int64
+}
+
+func (s *spool) Write(p []byte) (int, error) {
+	s.size += int64(len(p))
+	if s.file == nil && s.buf.Len()+len(p) <= spoolMemory {
+		return s.buf.Write(p)
+	}
+	if s.file == nil {
+		f, err := os.CreateTemp("", "elf-*")
+		if err != nil {
+			return 0, err
+		}
+		s.file = f
+		if _, err := s.buf.WriteTo(f); err != nil {
+			return 0, err
+		}
+	}
+	return s.file.Write(p)
+}
+
+// ReaderAt 返回已写入内容的随机访问reader
+func (s *spool) ReaderAt() io.ReaderAt {
+	if s.file != nil {
+		return s.file
+	}
+	return bytes.NewReader(s.buf.Bytes())
+}
+
+// Close 删除临时文件
+func (s *spool) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	s.file.Close()
+	return os.Remove(s.file.Name())
+}
+
+// SpoolReader 读取r的全部内容以便随机访问，用于squashfs等需要随机读取的格式
+// 返回内容的长度，Closer删除临时文件
+func SpoolReader(r io.Reader) (io.ReaderAt, int64, io.Closer, error) {
+	sp := new(spool)
+	if _, err := io.Copy(sp, r); err != nil {
+		sp.Close()
+		return nil, 0, nil, err
+	}
+	return sp.ReaderAt(), sp.size, sp, nil
+}
@@ -5,23 +5,114 @@ import (
 	"crypto/md5"
 	"fmt"
 	"io"
+	"strings"
 
 	jsoniter "github.com/json-iterator/go"
 	"github.com/pkg/errors"
 )
 
-func generateQueryStr(index string, hashes ...string) string {
+// hashFields 根据摘要长度判断算法，返回需要匹配的字段
+// md5同时匹配旧数据中的hashes.key
+func hashFields(h string) ([]string, error) {
+	switch len(h) {
+	case 32:
+		return []string{"files.md5", "hashes.key"}, nil
+	case 40:
+		return []string{"files.sha1"}, nil
+	case 64:
+		return []string{"files.sha256"}, nil
+	}
+	return nil, errors.Errorf("unknown digest %s, expect md5, sha1 or sha256", h)
+}
+
+func generateDependentsQuery(index string, names ...string) (string, error) {
+	var buf bytes.Buffer
+	meta := []byte(fmt.Sprintf(`{ "index" : "%s" }%s`, index, "\n"))
+
+	for _, name := range names {
+		queryMap := map[string]interface{}{
+			"query": map[string]interface{}{
+				"term": map[string]interface{}{
+					"relations.alternatives.name.keyword": strings.TrimSpace(name),
+				},
+			},
+			"size": 1000,
+		}
+		query, err := jsoniter.Marshal(queryMap)
+		if err != nil {
+			return "", err
+		}
+		query = append(query, '\n')
+
+		buf.Grow(len(meta) + len(query))
+		buf.Write(meta)
+		buf.Write(query)
+	}
+
+	return buf.String(), nil
+}
+
+func generateBuildIDQuery(index string, ids ...string) (string, error) {
+	var buf bytes.Buffer
+	meta := []byte(fmt.Sprintf(`{ "index" : "%s" }%s`, index, "\n"))
+
+	for _, id := range ids {
+		queryMap := map[string]interface{}{
+			"query": map[string]interface{}{
+				"term": map[string]interface{}{
+					"files.elf.build_id": strings.ToLower(strings.TrimSpace(id)),
+				},
+			},
+		}
+		query, err := jsoniter.Marshal(queryMap)
+		if err != nil {
+			return "", err
+		}
+		query = append(query, '\n')
+
+		buf.Grow(len(meta) + len(query))
+		buf.Write(meta)
+		buf.Write(query)
+	}
+
+	return buf.String(), nil
+}
+
+func generateQueryStr(index string, hashes ...string) (string, error) {
 	var buf bytes.Buffer
 	meta := []byte(fmt.Sprintf(`{ "index" : "%s" }%s`, index, "\n"))
 
 	for _, h := range hashes {
-		query := []byte(fmt.Sprintf(`{ "query" : { "term": { "hashes.key": "%s" } } }%s`, h, "\n"))
+		h = strings.ToLower(strings.TrimSpace(h))
+		fields, err := hashFields(h)
+		if err != nil {
+			return "", err
+		}
+		should := make([]map[string]interface{}, 0, len(fields))
+		for _, f := range fields {
+			should = append(should, map[string]interface{}{
+				"term": map[string]interface{}{f: h},
+			})
+		}
+		queryMap := map[string]interface{}{
+			"query": map[string]interface{}{
+				"bool": map[string]interface{}{
+					"should": should,
+				},
+			},
+		}
+		query, err := jsoniter.Marshal(queryMap)
+		if err != nil {
+			return "", err
+		}
+		query = append(query, '\n')
+
 		buf.Grow(len(meta) + len(query))
 		buf.Write(meta)
 		buf.Write(query)
 	}
 
-	return buf.String()
+	return buf.String(), nil
 }
 
 func GenerateQueryForVuln(index string, pkms ...*PkgKeyMessage) (string, error) {
@@ -3,6 +3,7 @@ module query
 go 1.21.4
 
 require (
+	get_package_md5 v0.0.0
 	github.com/elastic/go-elasticsearch/v7 v7.17.10
 	github.com/elastic/go-elasticsearch/v8 v8.11.1
 	github.com/json-iterator/go v1.1.12
@@ -15,3 +16,5 @@ require (
 	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
 	github.com/modern-go/reflect2 v1.0.2 // indirect
 )
+
+replace get_package_md5 => ../get_package_md5
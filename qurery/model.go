@@ -7,20 +7,37 @@ import (
 )
 
 type Document struct {
-	Os           string   `json:"os"`
-	Epoch        int      `json:"epoch"`
-	Release      string   `json:"release"`
-	Manager      string   `json:"manager"`
-	Name         string   `json:"name"`
-	Source       string   `json:"source"`
-	Version      string   `json:"version"`
-	Architecture string   `json:"architecture"`
-	Maintainer   string   `json:"maintainer"`
-	Homepage     string   `json:"homepage"`
-	Description  string   `json:"description"`
-	License      []string `json:"license"`
-	Depends      []string `json:"depends"`
-	Hashes       []Hash   `json:"hashes"`
+	Os           string     `json:"os"`
+	Epoch        int        `json:"epoch"`
+	Release      string     `json:"release"`
+	Manager      string     `json:"manager"`
+	Name         string     `json:"name"`
+	Source       string     `json:"source"`
+	Version      string     `json:"version"`
+	Architecture string     `json:"architecture"`
+	Maintainer   string     `json:"maintainer"`
+	Homepage     string     `json:"homepage"`
+	Description  string     `json:"description"`
+	License      []string   `json:"license"`
+	Depends      []string   `json:"depends"`
+	Relations    []Relation `json:"relations"`
+	Hashes       []Hash     `json:"hashes"`
+	Files        []File     `json:"files"`
+}
+
+// Relation 包之间的关系，Kind为depends、provides、conflicts等，Alternatives中满足其一即可
+type Relation struct {
+	Kind         string       `json:"kind"`
+	Alternatives []Dependency `json:"alternatives"`
+}
+
+// Dependency Namespace为so、file、cmd、pkgconfig等，普通包名为空
+type Dependency struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Operator  string `json:"operator"`
+	Version   string `json:"version"`
+	Arch      string `json:"arch"`
 }
 
 type Hash struct {
@@ -28,6 +45,123 @@ type Hash struct {
 	Value string `json:"value"`
 }
 
+// File 包内的ELF文件及其摘要
+type File struct {
+	Path    string     `json:"path"`
+	Size    int64      `json:"size"`
+	Mode    string     `json:"mode"`
+	MD5     string     `json:"md5"`
+	SHA1    string     `json:"sha1"`
+	SHA256  string     `json:"sha256"`
+	TLSH    string     `json:"tlsh"`
+	Elf     *ElfInfo   `json:"elf"`
+	Build   *BuildInfo `json:"build"`
+	License string     `json:"license"`
+}
+
+// ElfInfo ELF文件的元数据
+type ElfInfo struct {
+	BuildID     string   `json:"build_id"`
+	Soname      string   `json:"soname"`
+	Needed      []string `json:"needed"`
+	Machine     string   `json:"machine"`
+	Class       string   `json:"class"`
+	Endian      string   `json:"endian"`
+	Interpreter string   `json:"interpreter"`
+	Stripped    bool     `json:"stripped"`
+	Type        string   `json:"type"`
+}
+
+// BuildInfo 二进制中嵌入的构建信息，Lang为go或rust
+type BuildInfo struct {
+	Lang      string    `json:"lang"`
+	Toolchain string    `json:"toolchain"`
+	Main      *Module   `json:"main"`
+	Deps      []*Module `json:"deps"`
+}
+
+type Module struct {
+	Path    string  `json:"path"`
+	Version string  `json:"version"`
+	Sum     string  `json:"sum"`
+	Source  string  `json:"source"`
+	Replace *Module `json:"replace"`
+}
+
+// dependentKinds 反向依赖中关心的关系
+var dependentKinds = map[string]bool{
+	"depends":     true,
+	"pre-depends": true,
+	"recommends":  true,
+	"conflicts":   true,
+	"breaks":      true,
+}
+
+// filterDependents es中relations未使用nested映射，kind与名称可能来自不同的关系，这里再按文档逐条核对
+func filterDependents(name string, docs []Document) []Document {
+	var result []Document
+	for _, d := range docs {
+		if d.dependsOn(name) {
+			result = append(result, d)
+		}
+	}
+	return result
+}
+
+func (d *Document) dependsOn(name string) bool {
+	for _, r := range d.Relations {
+		if !dependentKinds[r.Kind] {
+			continue
+		}
+		for _, alt := range r.Alternatives {
+			if alt.Name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// EmbeddedComponent 编译进包内二进制的组件，Binary为所在文件的路径
+type EmbeddedComponent struct {
+	Lang    string
+	Name    string
+	Version string
+	Binary  string
+}
+
+// EmbeddedComponents 返回包内所有二进制嵌入的依赖组件，同一二进制内按名称与版本去重
+// 被replace的go模块以替换后的模块为准
+func (d *Document) EmbeddedComponents() []EmbeddedComponent {
+	var result []EmbeddedComponent
+	for _, f := range d.Files {
+		if f.Build == nil {
+			continue
+		}
+		seen := map[string]bool{}
+		for _, m := range f.Build.Deps {
+			if m == nil {
+				continue
+			}
+			if m.Replace != nil {
+				m = m.Replace
+			}
+			key := m.Path + "@" + m.Version
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			result = append(result, EmbeddedComponent{
+				Lang:    f.Build.Lang,
+				Name:    m.Path,
+				Version: m.Version,
+				Binary:  f.Path,
+			})
+		}
+	}
+	return result
+}
+
 type OS struct {
 	Family string
 	Name   string
@@ -3,8 +3,14 @@ package qurery
 type Search interface {
 	// SearchByFilePath 通过文件的实际路径查询
 	SearchByFilePath(index string, filePaths ...string) (map[string][]Document, error)
-	// SearchByHash 通过hash值查询
+	// SearchByHash 通过hash值查询，根据长度识别md5、sha1、sha256
 	SearchByHash(index string, hashes ...string) (map[string][]Document, error)
+	// SearchByBuildID 通过ELF的GNU build-id查询，strip后的文件依然可以命中
+	SearchByBuildID(index string, ids ...string) (map[string][]Document, error)
+	// SearchDependents 反向依赖查询，返回依赖、推荐或冲突于names(包名或so库名)的包
+	SearchDependents(index string, names ...string) (map[string][]Document, error)
+	// SearchSimilar 通过TLSH查询相似的文件，返回距离不超过threshold的包，按距离升序排列
+	SearchSimilar(index string, threshold int, digests ...string) (map[string][]Similar, error)
 	// SearchPkgVuln 返回pkg对应的漏洞编号列表
 	SearchPkgVuln(index string, pkms ...*PkgKeyMessage) (map[*PkgKeyMessage][]string, error)
 }
@@ -0,0 +1,99 @@
+package qurery
+
+import (
+	"fmt"
+	"get_package_md5/tlsh"
+	"sort"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+)
+
+// maxTlshDistance 超过该距离的两个文件基本无关，用于将距离换算为相似度
+const maxTlshDistance = 300
+
+// Similar 相似查询的一条结果，File为包内与查询最接近的文件
+type Similar struct {
+	Document Document
+	File     File
+	Distance int
+	// Score 相似度，1表示相同，0表示无关
+	Score float64
+}
+
+func generateSimilarQuery(index string, digests ...string) (string, error) {
+	var buf strings.Builder
+	meta := fmt.Sprintf(`{ "index" : "%s" }%s`, index, "\n")
+
+	for _, s := range digests {
+		s = strings.ToUpper(strings.TrimSpace(s))
+		bands := tlsh.Bands(s)
+		if bands == nil {
+			return "", errors.Errorf("invalid tlsh %s", s)
+		}
+		// 每个相同的片段各计一分，es按命中的片段数排序候选
+		should := []map[string]interface{}{
+			{"term": map[string]interface{}{"files.tlsh.keyword": s}},
+		}
+		for _, b := range bands {
+			should = append(should, map[string]interface{}{
+				"term": map[string]interface{}{"files.tlsh_bands.keyword": b},
+			})
+		}
+		queryMap := map[string]interface{}{
+			"query": map[string]interface{}{
+				"bool": map[string]interface{}{
+					"should":               should,
+					"minimum_should_match": 1,
+				},
+			},
+			"size": 100,
+		}
+		query, err := jsoniter.Marshal(queryMap)
+		if err != nil {
+			return "", err
+		}
+		buf.WriteString(meta)
+		buf.Write(query)
+		buf.WriteByte('\n')
+	}
+
+	return buf.String(), nil
+}
+
+// rankSimilar 计算候选包中每个文件与digest的距离，保留距离不超过threshold的包并按距离升序排列
+func rankSimilar(digest string, docs []Document, threshold int) []Similar {
+	digest = strings.TrimSpace(digest)
+	if tlsh.Bands(digest) == nil {
+		return nil
+	}
+
+	var result []Similar
+	for _, doc := range docs {
+		best := -1
+		var file File
+		for _, f := range doc.Files {
+			if f.TLSH == "" {
+				continue
+			}
+			dist, err := tlsh.Diff(digest, f.TLSH)
+			if err != nil {
+				continue
+			}
+			if best == -1 || dist < best {
+				best, file = dist, f
+			}
+		}
+		if best == -1 || best > threshold {
+			continue
+		}
+		score := 1 - float64(best)/maxTlshDistance
+		if score < 0 {
+			score = 0
+		}
+		result = append(result, Similar{Document: doc, File: file, Distance: best, Score: score})
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Distance < result[j].Distance })
+	return result
+}
@@ -34,8 +34,39 @@ func (v *V7) SearchByFilePath(index string, ps ...string) (map[string][]Document
 }
 
 func (v *V7) SearchByHash(index string, hashes ...string) (map[string][]Document, error) {
+	queryStr, err := generateQueryStr(index, hashes...)
+	if err != nil {
+		return nil, err
+	}
+	return v.searchDocs(queryStr, hashes)
+}
+
+func (v *V7) SearchByBuildID(index string, ids ...string) (map[string][]Document, error) {
+	queryStr, err := generateBuildIDQuery(index, ids...)
+	if err != nil {
+		return nil, err
+	}
+	return v.searchDocs(queryStr, ids)
+}
+
+func (v *V7) SearchDependents(index string, names ...string) (map[string][]Document, error) {
+	queryStr, err := generateDependentsQuery(index, names...)
+	if err != nil {
+		return nil, err
+	}
+	docs, err := v.searchDocs(queryStr, names)
+	if err != nil {
+		return nil, err
+	}
+	for name, ds := range docs {
+		docs[name] = filterDependents(name, ds)
+	}
+	return docs, nil
+}
+
+// searchDocs 执行msearch，按顺序将每个查询命中的文档对应到keys
+func (v *V7) searchDocs(queryStr string, keys []string) (map[string][]Document, error) {
 	var docss [][]Document
-	queryStr := generateQueryStr(index, hashes...)
 
 	res, err := v.cli.Msearch(strings.NewReader(queryStr))
 	if err != nil {
@@ -74,11 +105,46 @@ func (v *V7) SearchByHash(index string, hashes ...string) (map[string][]Document
 	}
 
 	m := map[string][]Document{}
-	for i, h := range hashes {
-		m[h] = docss[i]
+	for i, k := range keys {
+		m[k] = docss[i]
+	}
+
+	return m, nil
+}
+
+func (v *V7) SearchSimilar(index string, threshold int, digests ...string) (map[string][]Similar, error) {
+	queryStr, err := generateSimilarQuery(index, digests...)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := v.cli.Msearch(strings.NewReader(queryStr))
+	if err != nil {
+		return nil, err
 	}
+	defer res.Body.Close()
 
-	return m, err
+	if res.IsError() {
+		d, _ := io.ReadAll(res.Body)
+		return nil, errors.Errorf("%s", d)
+	}
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	docss, err := parseRespData(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(docss) != len(digests) {
+		return nil, errors.New("the search does not correspond to the number of responses")
+	}
+
+	m := map[string][]Similar{}
+	for i, d := range digests {
+		m[d] = rankSimilar(d, docss[i], threshold)
+	}
+	return m, nil
 }
 
 func (v *V7) SearchPkgVuln(index string, pkms ...*PkgKeyMessage) (map[*PkgKeyMessage][]string, error) {
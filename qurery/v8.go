@@ -33,8 +33,39 @@ func (v *V8) SearchByFilePath(index string, filePaths ...string) (map[string][]D
 }
 
 func (v *V8) SearchByHash(index string, hashes ...string) (map[string][]Document, error) {
+	queryStr, err := generateQueryStr(index, hashes...)
+	if err != nil {
+		return nil, err
+	}
+	return v.searchDocs(queryStr, hashes)
+}
+
+func (v *V8) SearchByBuildID(index string, ids ...string) (map[string][]Document, error) {
+	queryStr, err := generateBuildIDQuery(index, ids...)
+	if err != nil {
+		return nil, err
+	}
+	return v.searchDocs(queryStr, ids)
+}
+
+func (v *V8) SearchDependents(index string, names ...string) (map[string][]Document, error) {
+	queryStr, err := generateDependentsQuery(index, names...)
+	if err != nil {
+		return nil, err
+	}
+	docs, err := v.searchDocs(queryStr, names)
+	if err != nil {
+		return nil, err
+	}
+	for name, ds := range docs {
+		docs[name] = filterDependents(name, ds)
+	}
+	return docs, nil
+}
+
+// searchDocs 执行msearch，按顺序将每个查询命中的文档对应到keys
+func (v *V8) searchDocs(queryStr string, keys []string) (map[string][]Document, error) {
 	var docss [][]Document
-	queryStr := generateQueryStr(index, hashes...)
 
 	res, err := v.cli.Msearch(strings.NewReader(queryStr))
 	if err != nil {
@@ -73,14 +104,49 @@ func (v *V8) SearchByHash(index string, hashes ...string) (map[string][]Document
 	}
 
 	m := map[string][]Document{}
-	for i, h := range hashes {
-		m[h] = docss[i]
+	for i, k := range keys {
+		m[k] = docss[i]
 	}
 
-	return m, err
+	return m, nil
 }
 
 // SearchPkgVuln 返回pkg对应的漏洞编号列表
+func (v *V8) SearchSimilar(index string, threshold int, digests ...string) (map[string][]Similar, error) {
+	queryStr, err := generateSimilarQuery(index, digests...)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := v.cli.Msearch(strings.NewReader(queryStr))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		d, _ := io.ReadAll(res.Body)
+		return nil, errors.Errorf("%s", d)
+	}
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	docss, err := parseRespData(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(docss) != len(digests) {
+		return nil, errors.New("the search does not correspond to the number of responses")
+	}
+
+	m := map[string][]Similar{}
+	for i, d := range digests {
+		m[d] = rankSimilar(d, docss[i], threshold)
+	}
+	return m, nil
+}
+
 func (v *V8) SearchPkgVuln(index string, pkms ...*PkgKeyMessage) (map[*PkgKeyMessage][]string, error) {
 	if err := v.getCpe(index, pkms...); err != nil {
 		return nil, err